@@ -15,9 +15,29 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/metrics/session": {
-            "post": {
-                "description": "Write session metrics to the server",
+        "/info": {
+            "get": {
+                "description": "Get the active server configuration, such as the session id extraction strategy",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get server info",
+                "responses": {
+                    "200": {
+                        "description": "Server configuration",
+                        "schema": {
+                            "$ref": "#/definitions/http.InfoResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/insights/graph-determinism": {
+            "get": {
+                "description": "Compare the execution paths, the time-ordered span names, of the sessions of an app. The score is the fraction of sessions following the most frequent path",
                 "consumes": [
                     "application/json"
                 ],
@@ -27,29 +47,96 @@ const docTemplate = `{
                 "tags": [
                     "APIs"
                 ],
-                "summary": "Write session metrics",
+                "summary": "Get the graph determinism of an app",
                 "parameters": [
                     {
-                        "description": "Metric to write",
-                        "name": "metric",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "example": "\"ml-service\"",
+                        "description": "App name, matched against the span service name",
+                        "name": "app_name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T15:04:05Z\"",
+                        "description": "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T18:04:05Z\"",
+                        "description": "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "end_time",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Graph determinism\" example({\"app_name\": \"ml-service\", \"session_count\": 4, \"unique_path_count\": 2, \"dominant_path\": [\"agent\", \"llm_call\", \"tool_call\"], \"dominant_path_count\": 3, \"score\": 0.75})",
                         "schema": {
-                            "$ref": "#/definitions/http.CreateMetric"
+                            "$ref": "#/definitions/models.GraphDeterminism"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
                         }
                     }
+                }
+            }
+        },
+        "/insights/metrics/matrix": {
+            "get": {
+                "description": "Get the average of each requested metric key per app. Numeric strings are averaged as numbers, and apps without a numeric value for a key get null",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get the metric matrix of apps by metric key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"accuracy,latency_ms\"",
+                        "description": "Comma-separated metric keys (max 50)",
+                        "name": "keys",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"session\"",
+                        "description": "Metric scope to read: session or span (default session)",
+                        "name": "scope",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Metric created successfully",
+                    "200": {
+                        "description": "Average per app and metric key\" example({\"keys\": [\"accuracy\", \"latency_ms\"], \"apps\": [{\"app_name\": \"ml-service\", \"values\": {\"accuracy\": 0.95, \"latency_ms\": null}}]})",
                         "schema": {
-                            "$ref": "#/definitions/http.Metric"
+                            "$ref": "#/definitions/models.MetricMatrix"
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
                     "500": {
@@ -61,9 +148,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/metrics/session/{session_id}": {
+        "/insights/spans/durations": {
             "get": {
-                "description": "Get metrics by session ID",
+                "description": "Get count, average, max and p95 span duration in milliseconds grouped by span name, optionally for a single service",
                 "consumes": [
                     "application/json"
                 ],
@@ -73,31 +160,46 @@ const docTemplate = `{
                 "tags": [
                     "APIs"
                 ],
-                "summary": "Get metrics by session ID",
+                "summary": "Get span duration statistics",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"session_abc123\"",
-                        "description": "Session ID",
-                        "name": "session_id",
-                        "in": "path",
+                        "example": "\"2023-06-25T15:04:05Z\"",
+                        "description": "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T18:04:05Z\"",
+                        "description": "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "end_time",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"ml-service\"",
+                        "description": "Service name",
+                        "name": "service_name",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of metrics for the session\" example([{\"id\": \"metric_001\", \"span_id\": \"span_abc123\", \"trace_id\": \"trace_def456\", \"session_id\": \"session_abc123\", \"timestamp\": \"2023-06-25T15:30:00Z\", \"metrics\": {\"accuracy\": \"0.95\", \"latency_ms\": \"120\"}, \"app_name\": \"ml-service\", \"app_id\": \"app-001\"}])",
+                        "description": "Duration statistics per span name",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/http.Metric"
+                                "$ref": "#/definitions/models.SpanDurationStat"
                             }
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
                     "500": {
@@ -109,9 +211,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/metrics/span": {
-            "post": {
-                "description": "Write span metrics to the server",
+        "/insights/tokens/sessions": {
+            "get": {
+                "description": "Get the total llm.usage.total_tokens of every session in the time window, largest first",
                 "consumes": [
                     "application/json"
                 ],
@@ -121,29 +223,48 @@ const docTemplate = `{
                 "tags": [
                     "APIs"
                 ],
-                "summary": "Write span metrics",
+                "summary": "Get token usage per session",
                 "parameters": [
                     {
-                        "description": "Metric to write",
-                        "name": "metric",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/http.CreateMetric"
-                        }
+                        "type": "string",
+                        "example": "\"2023-06-25T15:04:05Z\"",
+                        "description": "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T18:04:05Z\"",
+                        "description": "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "end_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number starting at 0 (default 0)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
                     }
                 ],
                 "responses": {
-                    "201": {
-                        "description": "Metric created successfully",
+                    "200": {
+                        "description": "Token usage per session\" example({\"data\": [{\"session_id\": \"session_abc123\", \"total_tokens\": 1520}], \"total\": 1})",
                         "schema": {
-                            "$ref": "#/definitions/http.Metric"
+                            "$ref": "#/definitions/models.SessionTokenUsageResponse"
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
                     "500": {
@@ -155,9 +276,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/metrics/span/{span_id}": {
+        "/metrics/apps": {
             "get": {
-                "description": "Get metrics by span ID",
+                "description": "Get the distinct app name and app id pairs that have written metrics",
                 "consumes": [
                     "application/json"
                 ],
@@ -167,31 +288,37 @@ const docTemplate = `{
                 "tags": [
                     "APIs"
                 ],
-                "summary": "Get metrics by span ID",
+                "summary": "List applications with metrics",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"span\"",
-                        "description": "Span ID",
-                        "name": "span_id",
-                        "in": "path",
-                        "required": true
+                        "example": "\"2023-06-25T15:04:05Z\"",
+                        "description": "Start time in ISO 8601 format, normalized to UTC",
+                        "name": "start_time",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T18:04:05Z\"",
+                        "description": "End time in ISO 8601 format, normalized to UTC",
+                        "name": "end_time",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of metrics for the span\" example([{\"id\": \"metric_001\", \"span_id\": \"span_abc123\", \"trace_id\": \"trace_def456\", \"session_id\": \"session_abc123\", \"timestamp\": \"2023-06-25T15:30:00Z\", \"metrics\": {\"accuracy\": \"0.95\", \"latency_ms\": \"120\"}, \"app_name\": \"ml-service\", \"app_id\": \"app-001\"}])",
+                        "description": "List of applications\" example([{\"app_name\": \"ml-service\", \"app_id\": \"app-001\"}])",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/http.Metric"
+                                "$ref": "#/definitions/models.AppInfo"
                             }
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
                     "500": {
@@ -203,9 +330,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/traces/session/{session_id}": {
+        "/metrics/compare": {
             "get": {
-                "description": "Get traces by session ID",
+                "description": "Get the latest value of every metric key of two sessions side by side, with the delta (session_b - session_a) when both values are numeric. Keys present in only one session have a null value for the other one",
                 "consumes": [
                     "application/json"
                 ],
@@ -215,32 +342,96 @@ const docTemplate = `{
                 "tags": [
                     "APIs"
                 ],
-                "summary": "Get traces by session ID",
+                "summary": "Compare the metrics of two sessions",
                 "parameters": [
                     {
                         "type": "string",
                         "example": "\"session_abc123\"",
-                        "description": "Session ID",
-                        "name": "session_id",
-                        "in": "path",
+                        "description": "First session ID",
+                        "name": "session_a",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"session_def456\"",
+                        "description": "Second session ID",
+                        "name": "session_b",
+                        "in": "query",
                         "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"session\"",
+                        "description": "Metric scope to read: session or span",
+                        "name": "scope",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of traces for the session\" example([{\"trace_id\": \"trace_def456\", \"span_name\": \"ml_inference\", \"timestamp\": \"2023-06-25T15:30:00Z\"}, {\"trace_id\": \"trace_ghi789\", \"span_name\": \"data_processing\", \"timestamp\": \"2023-06-25T15:31:00Z\"}])",
+                        "description": "Metrics of both sessions by key\" example({\"session_a\": \"session_abc123\", \"session_b\": \"session_def456\", \"scope\": \"session\", \"metrics\": {\"latency_ms\": {\"session_a\": \"120\", \"session_b\": \"100\", \"delta\": -20}, \"accuracy\": {\"session_a\": \"0.95\", \"session_b\": null}}})",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/http.Trace"
-                            }
+                            "$ref": "#/definitions/models.MetricComparisonResponse"
                         }
                     },
                     "400": {
                         "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
                         "schema": {
                             "type": "string"
                         }
+                    }
+                }
+            }
+        },
+        "/metrics/session": {
+            "post": {
+                "description": "Write session metrics to the server",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Write session metrics",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Replace stored metrics of the same session, span and metric key instead of appending, metrics without a span_id are keyed on the empty span",
+                        "name": "upsert",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Metric to write",
+                        "name": "metric",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateMetric"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Metric created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/http.Metric"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
                     },
                     "500": {
                         "description": "Internal server error",
@@ -251,9 +442,9 @@ const docTemplate = `{
                 }
             }
         },
-        "/traces/sessions": {
+        "/metrics/session/{session_id}": {
             "get": {
-                "description": "Get sessions by start and end time",
+                "description": "Get metrics by session ID",
                 "consumes": [
                     "application/json"
                 ],
@@ -263,39 +454,65 @@ const docTemplate = `{
                 "tags": [
                     "APIs"
                 ],
-                "summary": "Get sessions",
+                "summary": "Get metrics by session ID",
                 "parameters": [
                     {
                         "type": "string",
-                        "example": "\"2023-06-25T15:04:05Z\"",
-                        "description": "Start time in ISO 8601 UTC format (e.g. 2023-06-25T15:04:05Z)",
-                        "name": "start_time",
-                        "in": "query",
+                        "example": "\"session_abc123\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "integer",
+                        "example": 0,
+                        "description": "Zero-based page number, used with limit",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 50,
+                        "description": "Maximum number of metrics per page (all metrics when omitted)",
+                        "name": "limit",
+                        "in": "query"
+                    },
                     {
                         "type": "string",
-                        "example": "\"2023-06-25T18:04:05Z\"",
-                        "description": "End time in ISO 8601 UTC format (e.g. 2023-06-25T15:04:05Z)",
-                        "name": "end_time",
-                        "in": "query",
-                        "required": true
+                        "example": "\"timestamp_desc\"",
+                        "description": "Sort order: timestamp_asc or timestamp_desc (default)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"session\"",
+                        "description": "Metric scope to read: session or span",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return numeric-looking string metric values as JSON numbers",
+                        "name": "typed",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "List of session IDs with minimum timestamps\" example([{\"id\": \"session_abc123\", \"start_timestamp\": \"2023-06-25T15:30:00Z\"}, {\"id\": \"session_def456\", \"start_timestamp\": \"2023-06-25T16:15:00Z\"}])",
+                        "description": "List of metrics for the session\" example([{\"id\": \"metric_001\", \"span_id\": \"span_abc123\", \"trace_id\": \"trace_def456\", \"session_id\": \"session_abc123\", \"timestamp\": \"2023-06-25T15:30:00Z\", \"metrics\": {\"accuracy\": \"0.95\", \"latency_ms\": \"120\"}, \"app_name\": \"ml-service\", \"app_id\": \"app-001\"}])",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/http.SessionID"
+                                "$ref": "#/definitions/http.Metric"
                             }
                         }
                     },
                     "400": {
                         "description": "Bad request",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/http.ErrorResponse"
                         }
                     },
                     "500": {
@@ -306,10 +523,935 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "http.CreateMetric": {
+        },
+        "/metrics/session/{session_id}/rollup": {
+            "get": {
+                "description": "Aggregate one metric key over the span metrics of a session. Values must be numbers or numeric strings",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Roll up span metrics to a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"session_abc123\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"latency_ms\"",
+                        "description": "Metric key to aggregate",
+                        "name": "key",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"sum\"",
+                        "description": "Aggregation: sum, avg, min or max",
+                        "name": "agg",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Aggregated value",
+                        "schema": {
+                            "$ref": "#/definitions/models.MetricRollup"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No numeric values found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics/span": {
+            "post": {
+                "description": "Write span metrics to the server",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Write span metrics",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Replace stored metrics of the same session, span and metric key instead of appending, span_id is required",
+                        "name": "upsert",
+                        "in": "query"
+                    },
+                    {
+                        "description": "Metric to write",
+                        "name": "metric",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/http.CreateMetric"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Metric created successfully",
+                        "schema": {
+                            "$ref": "#/definitions/http.Metric"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics/span/{span_id}": {
+            "get": {
+                "description": "Get metrics by span ID",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get metrics by span ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"span\"",
+                        "description": "Span ID",
+                        "name": "span_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "example": 0,
+                        "description": "Zero-based page number, used with limit",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 50,
+                        "description": "Maximum number of metrics per page (all metrics when omitted)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"timestamp_desc\"",
+                        "description": "Sort order: timestamp_asc or timestamp_desc (default)",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"session\"",
+                        "description": "Metric scope to read: session or span",
+                        "name": "scope",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Return numeric-looking string metric values as JSON numbers",
+                        "name": "typed",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of metrics for the span\" example([{\"id\": \"metric_001\", \"span_id\": \"span_abc123\", \"trace_id\": \"trace_def456\", \"session_id\": \"session_abc123\", \"timestamp\": \"2023-06-25T15:30:00Z\", \"metrics\": {\"accuracy\": \"0.95\", \"latency_ms\": \"120\"}, \"app_name\": \"ml-service\", \"app_id\": \"app-001\"}])",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.Metric"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/metrics/spans": {
+            "get": {
+                "description": "Get the span ids of a session that have at least one metric, with the number of metrics per span",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "List the spans of a session that have metrics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"session_abc123\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"span\"",
+                        "description": "Metric scope to read: session or span (default span)",
+                        "name": "scope",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Spans with metrics\" example([{\"span_id\": \"span_abc123\", \"metric_count\": 2}])",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SpanMetricCount"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/ready": {
+            "get": {
+                "description": "Ping every backing service and report the status of each component. Returns 503 when a required component is down",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Check readiness",
+                "responses": {
+                    "200": {
+                        "description": "Every required component is up\" example({\"status\": \"ready\", \"components\": {\"data\": {\"status\": \"up\", \"required\": true}}})",
+                        "schema": {
+                            "$ref": "#/definitions/http.ReadyResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "A required component is down",
+                        "schema": {
+                            "$ref": "#/definitions/http.ReadyResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{session_id}": {
+            "delete": {
+                "description": "Delete every metric of a session and the spans whose extracted session id equals it exactly, returning the number of rows targeted per table. Spans are never deleted by suffix: with the raw session id strategy, spans whose session.id only ends with the id are kept, although GET /traces/session/{session_id} matches by suffix and still returns them. Deletes run as ClickHouse mutations, so purged rows can stay visible for a short time. Every table is attempted even when one fails, and a 500 returns the counts with the failed tables, so the request can be retried. Rejected in read-only mode",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Purge a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"session_abc123\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rows targeted per table",
+                        "schema": {
+                            "$ref": "#/definitions/models.PurgeResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Some tables were not purged",
+                        "schema": {
+                            "$ref": "#/definitions/models.PurgeResult"
+                        }
+                    },
+                    "503": {
+                        "description": "Read-only mode",
+                        "schema": {
+                            "$ref": "#/definitions/http.SimpleMessage"
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{session_id}/conversation": {
+            "get": {
+                "description": "Get the gen_ai prompt and completion messages of a session in chronological order. Prompts repeating the conversation history are only listed once. Sessions without gen_ai attributes return an empty conversation",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get the conversation of a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"session_abc123\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Conversation messages\" example({\"session_id\": \"session_abc123\", \"messages\": [{\"role\": \"user\", \"content\": \"What is the weather?\", \"span_id\": \"span_1\", \"timestamp\": \"2023-06-25T15:30:00Z\"}, {\"role\": \"assistant\", \"content\": \"It is sunny.\", \"span_id\": \"span_1\", \"timestamp\": \"2023-06-25T15:30:00Z\"}]})",
+                        "schema": {
+                            "$ref": "#/definitions/models.SessionConversation"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/attribute-keys": {
+            "get": {
+                "description": "Get the distinct span attribute keys seen in a time window, optionally for a single service",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get trace attribute keys",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T15:04:05Z\"",
+                        "description": "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T18:04:05Z\"",
+                        "description": "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "end_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"ml-service\"",
+                        "description": "Service name",
+                        "name": "service_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "example": 100,
+                        "description": "Maximum number of keys to return (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of attribute keys",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/schema": {
+            "get": {
+                "description": "Get the columns of the otel_traces table with their ClickHouse type and the key of the matching field in trace responses",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get the trace schema",
+                "responses": {
+                    "200": {
+                        "description": "Trace fields\" example([{\"field\": \"TraceId\", \"column\": \"TraceId\", \"json_key\": \"TraceId\", \"type\": \"String\"}])",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.TraceField"
+                            }
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/session/{session_id}": {
+            "get": {
+                "description": "Get traces by session ID. Send \"Accept: application/x-ndjson\" to stream one span per line instead of a JSON array",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "application/x-ndjson"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get traces by session ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"session_abc123\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"SpanName,Duration\"",
+                        "description": "Comma-separated trace fields to return, all fields when omitted (see /traces/schema for the keys)",
+                        "name": "fields",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "List of traces for the session\" example([{\"trace_id\": \"trace_def456\", \"span_name\": \"ml_inference\", \"timestamp\": \"2023-06-25T15:30:00Z\"}, {\"trace_id\": \"trace_ghi789\", \"span_name\": \"data_processing\", \"timestamp\": \"2023-06-25T15:31:00Z\"}])",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/http.Trace"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/session/{session_id}/callgraph/edges": {
+            "get": {
+                "description": "Get the directed transitions between consecutive spans of a session with their occurrence counts. The first span is reached from START and the last one leads to END",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get the call graph edges of a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Call graph edges\" example([{\"from_span\": \"START\", \"to_span\": \"agent\", \"count\": 1}, {\"from_span\": \"agent\", \"to_span\": \"llm_call\", \"count\": 3}])",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.CallGraphEdge"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/session/{session_id}/events": {
+            "get": {
+                "description": "Get the events of every span of a session as a flat list ordered by timestamp",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get the span events of a session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"session_abc123\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"tool_call\"",
+                        "description": "Only return events with this name",
+                        "name": "event_name",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Span events\" example([{\"trace_id\": \"trace_def456\", \"span_id\": \"span_abc123\", \"span_name\": \"agent\", \"timestamp\": \"2023-06-25T15:30:00Z\", \"name\": \"tool_call\", \"attributes\": {\"tool.name\": \"search\"}}])",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SpanEvent"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/session/{session_id}/span/{span_id}": {
+            "get": {
+                "description": "Get a specific span within a session",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get a single span by session ID and span ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8\"",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"f125e574-1e9e-40db-8720-82a62ff38464\"",
+                        "description": "Span ID",
+                        "name": "span_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The span data",
+                        "schema": {
+                            "$ref": "#/definitions/http.Trace"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Span not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/sessions": {
+            "get": {
+                "description": "Get sessions by start and end time",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get sessions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T15:04:05Z\"",
+                        "description": "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "start_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "example": "\"2023-06-25T18:04:05Z\"",
+                        "description": "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)",
+                        "name": "end_time",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Add the span count and error span count of each session",
+                        "name": "include_counts",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page number starting at 0, enables pagination (default 0)",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size, enables pagination (default 100, max 1000)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "next_cursor of the previous page, enables pagination and takes precedence over page. The response omits total",
+                        "name": "cursor",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "list of session IDs",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.SessionsResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/sessions/exists": {
+            "post": {
+                "description": "Split a list of session IDs into the ones that have traces and the ones that do not. Allowed in read-only mode",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Check which sessions have traces",
+                "parameters": [
+                    {
+                        "description": "Session IDs to check (max 1000)",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.SessionsExistRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Present and missing session IDs",
+                        "schema": {
+                            "$ref": "#/definitions/models.SessionsExistResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/sessions/spans": {
+            "get": {
+                "description": "Get span traces for multiple session IDs (comma-separated)",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get span traces by multiple session IDs",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"session_abc123,session_def456,session_ghi789\"",
+                        "description": "Comma-separated list of session IDs (max 50)",
+                        "name": "session_ids",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Map of session IDs to their traces with not found session information",
+                        "schema": {
+                            "$ref": "#/definitions/models.SessionSpansResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/traces/trace/{trace_id}/stats": {
+            "get": {
+                "description": "Get the span count, error span count, distinct services and total duration of a trace",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "APIs"
+                ],
+                "summary": "Get trace statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "example": "\"4bf92f3577b34da6a3ce929d0e0e4736\"",
+                        "description": "Trace ID",
+                        "name": "trace_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Trace statistics\" example({\"trace_id\": \"4bf92f3577b34da6a3ce929d0e0e4736\", \"span_count\": 12, \"error_span_count\": 1, \"services\": [\"api-gateway\", \"ml-service\"], \"total_duration_ms\": 1532.4})",
+                        "schema": {
+                            "$ref": "#/definitions/models.TraceStats"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad request",
+                        "schema": {
+                            "$ref": "#/definitions/http.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Trace not found",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "http.ComponentStatus": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "required": {
+                    "type": "boolean"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.CreateMetric": {
             "type": "object",
             "required": [
                 "app_id",
@@ -326,65 +1468,349 @@ const docTemplate = `{
                 "app_name": {
                     "type": "string"
                 },
-                "metrics": {
-                    "description": "Use json.RawMessage to store arbitrary JSON data",
-                    "type": "string",
-                    "example": "{\"key\":\"value\"}"
+                "metrics": {
+                    "description": "Use json.RawMessage to store arbitrary JSON data",
+                    "type": "string",
+                    "example": "{\"key\":\"value\"}"
+                },
+                "session_id": {
+                    "type": "string"
+                },
+                "span_id": {
+                    "type": "string"
+                },
+                "trace_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.InfoResponse": {
+            "type": "object",
+            "properties": {
+                "session_id_regex": {
+                    "type": "string"
+                },
+                "session_id_strategy": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.Metric": {
+            "type": "object",
+            "properties": {
+                "app_id": {
+                    "type": "string"
+                },
+                "app_name": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "metrics": {
+                    "description": "Use json.RawMessage to store arbitrary JSON data",
+                    "type": "string",
+                    "example": "{\"accuracy\":\"0.95\",\"latency_ms\":\"120\"}"
+                },
+                "session_id": {
+                    "type": "string"
+                },
+                "span_id": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "trace_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.ReadyResponse": {
+            "type": "object",
+            "properties": {
+                "components": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/http.ComponentStatus"
+                    }
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.SimpleMessage": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "http.Trace": {
+            "type": "object",
+            "properties": {
+                "duration": {
+                    "type": "integer"
+                },
+                "eventsAttributes": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "eventsName": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "eventsTimestamp": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "linksAttributes": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "linksSpanId": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "linksTraceId": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "linksTraceState": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "parentSpanId": {
+                    "type": "string"
+                },
+                "resourceAttributes": {
+                    "$ref": "#/definitions/models.AttributeMap"
+                },
+                "scopeName": {
+                    "type": "string"
+                },
+                "scopeVersion": {
+                    "type": "string"
+                },
+                "serviceName": {
+                    "type": "string"
+                },
+                "spanAttributes": {
+                    "$ref": "#/definitions/models.AttributeMap"
+                },
+                "spanId": {
+                    "type": "string"
+                },
+                "spanKind": {
+                    "type": "string"
+                },
+                "spanName": {
+                    "type": "string"
+                },
+                "statusCode": {
+                    "type": "string"
+                },
+                "statusMessage": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "traceId": {
+                    "type": "string"
+                },
+                "traceState": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AppInfo": {
+            "type": "object",
+            "properties": {
+                "app_id": {
+                    "type": "string"
+                },
+                "app_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.AttributeMap": {
+            "type": "object",
+            "additionalProperties": {
+                "type": "string"
+            }
+        },
+        "models.CallGraphEdge": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "from_span": {
+                    "type": "string"
+                },
+                "to_span": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.ConversationMessage": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "role": {
+                    "type": "string"
+                },
+                "span_id": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.GraphDeterminism": {
+            "type": "object",
+            "properties": {
+                "app_name": {
+                    "type": "string"
+                },
+                "dominant_path": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "dominant_path_count": {
+                    "type": "integer"
                 },
-                "session_id": {
-                    "type": "string"
+                "score": {
+                    "type": "number"
                 },
-                "span_id": {
-                    "type": "string"
+                "session_count": {
+                    "type": "integer"
                 },
-                "trace_id": {
-                    "type": "string"
+                "unique_path_count": {
+                    "type": "integer"
                 }
             }
         },
-        "http.Metric": {
+        "models.MetricComparison": {
             "type": "object",
             "properties": {
-                "app_id": {
-                    "type": "string"
+                "delta": {
+                    "type": "number"
                 },
-                "app_name": {
+                "session_a": {
                     "type": "string"
                 },
-                "id": {
+                "session_b": {
                     "type": "string"
-                },
+                }
+            }
+        },
+        "models.MetricComparisonResponse": {
+            "type": "object",
+            "properties": {
                 "metrics": {
-                    "description": "Use json.RawMessage to store arbitrary JSON data",
-                    "type": "string",
-                    "example": "{\"accuracy\":\"0.95\",\"latency_ms\":\"120\"}"
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/models.MetricComparison"
+                    }
                 },
-                "session_id": {
+                "scope": {
                     "type": "string"
                 },
-                "span_id": {
+                "session_a": {
                     "type": "string"
                 },
-                "timestamp": {
+                "session_b": {
                     "type": "string"
+                }
+            }
+        },
+        "models.MetricMatrix": {
+            "type": "object",
+            "properties": {
+                "apps": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.MetricMatrixRow"
+                    }
                 },
-                "trace_id": {
+                "keys": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.MetricMatrixRow": {
+            "type": "object",
+            "properties": {
+                "app_name": {
                     "type": "string"
+                },
+                "values": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "number"
+                    }
                 }
             }
         },
-        "http.SessionID": {
+        "models.MetricRollup": {
             "type": "object",
             "properties": {
-                "id": {
+                "agg": {
                     "type": "string"
                 },
-                "start_timestamp": {
+                "key": {
+                    "type": "string"
+                },
+                "session_id": {
                     "type": "string"
+                },
+                "value": {
+                    "type": "number"
                 }
             }
         },
-        "http.Trace": {
+        "models.OtelTraces": {
             "type": "object",
             "properties": {
                 "duration": {
@@ -442,10 +1868,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "resourceAttributes": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "string"
-                    }
+                    "$ref": "#/definitions/models.AttributeMap"
                 },
                 "scopeName": {
                     "type": "string"
@@ -457,10 +1880,7 @@ const docTemplate = `{
                     "type": "string"
                 },
                 "spanAttributes": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "string"
-                    }
+                    "$ref": "#/definitions/models.AttributeMap"
                 },
                 "spanId": {
                     "type": "string"
@@ -487,6 +1907,250 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "models.PurgeResult": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "error": {
+                    "type": "string"
+                },
+                "failed": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "session_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SessionConversation": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ConversationMessage"
+                    }
+                },
+                "session_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SessionSpansResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "array",
+                        "items": {
+                            "$ref": "#/definitions/models.OtelTraces"
+                        }
+                    }
+                },
+                "notfound_session_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.SessionTokenUsage": {
+            "type": "object",
+            "properties": {
+                "session_id": {
+                    "type": "string"
+                },
+                "total_tokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SessionTokenUsageResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SessionTokenUsage"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SessionUniqueID": {
+            "type": "object",
+            "properties": {
+                "error_count": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "prompt": {
+                    "type": "string"
+                },
+                "span_count": {
+                    "type": "integer"
+                },
+                "start_timestamp": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SessionsExistRequest": {
+            "type": "object",
+            "properties": {
+                "session_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.SessionsExistResponse": {
+            "type": "object",
+            "properties": {
+                "missing": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "present": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "models.SessionsResponse": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.SessionUniqueID"
+                    }
+                },
+                "next_cursor": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.SpanDurationStat": {
+            "type": "object",
+            "properties": {
+                "avg_duration_ms": {
+                    "type": "number"
+                },
+                "count": {
+                    "type": "integer"
+                },
+                "max_duration_ms": {
+                    "type": "number"
+                },
+                "p95_duration_ms": {
+                    "type": "number"
+                },
+                "span_name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SpanEvent": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "type": "string"
+                },
+                "span_id": {
+                    "type": "string"
+                },
+                "span_name": {
+                    "type": "string"
+                },
+                "timestamp": {
+                    "type": "string"
+                },
+                "trace_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.SpanMetricCount": {
+            "type": "object",
+            "properties": {
+                "metric_count": {
+                    "type": "integer"
+                },
+                "span_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TraceField": {
+            "type": "object",
+            "properties": {
+                "column": {
+                    "type": "string"
+                },
+                "field": {
+                    "type": "string"
+                },
+                "json_key": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TraceStats": {
+            "type": "object",
+            "properties": {
+                "error_span_count": {
+                    "type": "integer"
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "span_count": {
+                    "type": "integer"
+                },
+                "total_duration_ms": {
+                    "type": "number"
+                },
+                "trace_id": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
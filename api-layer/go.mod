@@ -6,6 +6,7 @@ module github.com/agntcy/telemetry-hub/api-layer
 go 1.24.2
 
 require (
+	github.com/ClickHouse/clickhouse-go/v2 v2.37.1
 	github.com/google/uuid v1.6.0
 	github.com/gorilla/mux v1.8.1
 	github.com/joho/godotenv v1.5.1
@@ -21,7 +22,6 @@ require (
 
 require (
 	github.com/ClickHouse/ch-go v0.66.0 // indirect
-	github.com/ClickHouse/clickhouse-go/v2 v2.37.1 // indirect
 	github.com/KyleBanks/depth v1.2.1 // indirect
 	github.com/andybalholm/brotli v1.1.1 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
@@ -10,10 +10,12 @@ import (
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/common"
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse"
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/handlers"
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/http"
 )
 
@@ -27,12 +29,24 @@ func main() {
 	baseUrl := flag.String("baseUrl", common.GetEnvString(common.BASE_URL, "localhost:8080"), "Base URL for the API")
 	// Start as test
 	test := flag.Bool("test", common.GetEnvBool("TEST_MODE", false), "Start as test")
+	// Reject every write request, reads keep working
+	readOnly := flag.Bool("readOnly", common.GetEnvBool(common.READ_ONLY, false), "Start in read-only mode")
+	// Reject request bodies with unknown fields instead of ignoring them
+	strictJSON := flag.Bool("strictJson", common.GetEnvBool(common.STRICT_JSON, false), "Reject request bodies with unknown JSON fields")
 
 	clickhouseUrl := flag.String("clickhouseUrl", common.GetEnvString(common.CLICKHOUSE_URL, "localhost"), "Clickhouse Url")
 	clickhouseUser := flag.String("clickhouseUser", common.GetEnvString(common.CLICKHOUSE_USER, "default"), "Clickhouse User")
 	clickhouseDB := flag.String("clickhouseDB", common.GetEnvString(common.CLICKHOUSE_DB, "default"), "Clickhouse DB")
 	clickhousePass := flag.String("clickhousePass", common.GetEnvString(common.CLICKHOUSE_PASS, "password"), "Clickhouse Password")
 	clickhousePort := flag.Int("clickhousePort", common.GetEnvInt(common.CLICKHOUSE_PORT, 9000), "Clickhouse Port")
+	sessionIDStrategy := flag.String("sessionIdStrategy", common.GetEnvString(common.SESSION_ID_STRATEGY, common.SESSION_ID_STRATEGY_RAW), "Session id extraction from span attributes: raw, split or regex")
+	sessionIDRegex := flag.String("sessionIdRegex", common.GetEnvString(common.SESSION_ID_REGEX, ""), "Regex extracting the session id, first capture group or whole match, used with the regex strategy")
+	// Delete metrics older than the retention, disabled when 0
+	metricRetentionDays := flag.Int("metricRetentionDays", common.GetEnvInt(common.METRIC_RETENTION_DAYS, 0), "Delete metrics older than this number of days, 0 keeps them forever")
+	metricRetentionInterval := flag.Duration("metricRetentionInterval", common.GetEnvDuration(common.METRIC_RETENTION_INTERVAL, time.Hour), "Interval between metric retention runs")
+	metricKeyAllowlist := flag.String("metricKeyAllowlist", common.GetEnvString(common.METRIC_KEY_ALLOWLIST, ""), "Metric keys allowed per app name (e.g. ml-service=accuracy|latency_ms,api-gateway=response_time), other apps are unrestricted")
+	metricKeyAllowlistMode := flag.String("metricKeyAllowlistMode", common.GetEnvString(common.METRIC_KEY_ALLOWLIST_MODE, common.METRIC_KEY_ALLOWLIST_MODE_REJECT), "How to handle metric keys outside the allowlist: reject or drop")
+	metricTables := flag.String("metricTables", common.GetEnvString(common.METRIC_TABLES, ""), "Metric table per scope (e.g. session=session_metrics,span=span_metrics), unmapped scopes use derived_metrics")
 
 	flag.Parse()
 
@@ -40,9 +54,33 @@ func main() {
 	logger.Zap.Info("allowOrigins", logger.String("allowOrigins", *allowOrigins))
 
 	logger.Zap.Info("test", logger.Bool("test", *test))
+	logger.Zap.Info("readOnly", logger.Bool("readOnly", *readOnly))
+	logger.Zap.Info("strictJson", logger.Bool("strictJson", *strictJSON))
 	logger.Zap.Info("clickhouseUrl", logger.String("dbUrl", *clickhouseUrl))
 	logger.Zap.Info("clickhouseUser", logger.String("dbUser", *clickhouseUser))
 	logger.Zap.Info("clickhousePort", logger.Int("dbPort", *clickhousePort))
+	logger.Zap.Info("metricTables", logger.String("metricTables", *metricTables))
+	logger.Zap.Info("metricRetention", logger.Int("metricRetentionDays", *metricRetentionDays), logger.Duration("metricRetentionInterval", *metricRetentionInterval))
+
+	metricTablesParsed, err := common.ParseMetricTables(*metricTables)
+	if err != nil {
+		logger.Zap.Fatal("Invalid metric tables", logger.Error(err))
+	}
+
+	logger.Zap.Info("metricKeyAllowlist", logger.String("metricKeyAllowlist", *metricKeyAllowlist), logger.String("metricKeyAllowlistMode", *metricKeyAllowlistMode))
+	metricKeyAllowlistParsed, err := common.ParseMetricKeyAllowlist(*metricKeyAllowlist)
+	if err != nil {
+		logger.Zap.Fatal("Invalid metric key allowlist", logger.Error(err))
+	}
+	if *metricKeyAllowlistMode != common.METRIC_KEY_ALLOWLIST_MODE_REJECT && *metricKeyAllowlistMode != common.METRIC_KEY_ALLOWLIST_MODE_DROP {
+		logger.Zap.Fatal("Invalid metric key allowlist mode, must be reject or drop", logger.String("metricKeyAllowlistMode", *metricKeyAllowlistMode))
+	}
+
+	logger.Zap.Info("sessionIdStrategy", logger.String("sessionIdStrategy", *sessionIDStrategy), logger.String("sessionIdRegex", *sessionIDRegex))
+	sessionIDStrategyParsed, err := handlers.NewSessionIDStrategy(*sessionIDStrategy, *sessionIDRegex)
+	if err != nil {
+		logger.Zap.Fatal("Invalid session id strategy", logger.Error(err))
+	}
 
 	var wg sync.WaitGroup
 	logger.Zap.Info("Starting server")
@@ -56,12 +94,24 @@ func main() {
 		Pass: *clickhousePass,
 		Port: *clickhousePort,
 		DB:   *clickhouseDB,
+
+		MetricTables:      metricTablesParsed,
+		SessionIDStrategy: sessionIDStrategyParsed,
 	}
 
 	if !*test {
 		clickhouseService.Init()
 	}
 
+	if *metricRetentionDays > 0 && !*test {
+		if *metricRetentionInterval <= 0 {
+			logger.Zap.Error("Invalid metric retention interval, using 1h", logger.Duration("metricRetentionInterval", *metricRetentionInterval))
+			*metricRetentionInterval = time.Hour
+		}
+		wg.Add(1)
+		go clickhouseService.RunMetricRetention(ctx, &wg, *metricRetentionDays, *metricRetentionInterval)
+	}
+
 	wg.Add(1)
 
 	httpServer := &http.HttpServer{
@@ -69,6 +119,14 @@ func main() {
 		Port:         *port,
 		DataService:  clickhouseService,
 		BaseUrl:      *baseUrl,
+		ReadOnly:     *readOnly,
+		StrictJSON:   *strictJSON,
+
+		SessionIDStrategy: sessionIDStrategyParsed.Name(),
+		SessionIDRegex:    sessionIDStrategyParsed.Regex(),
+
+		MetricKeyAllowlist:     metricKeyAllowlistParsed,
+		MetricKeyAllowlistMode: *metricKeyAllowlistMode,
 	}
 	go func() {
 
@@ -4,25 +4,83 @@
 package common
 
 const (
-	SERVER_PORT     = "SERVER_PORT"
-	ALLOW_ORIGINS   = "ALLOW_ORIGINS"
-	BASE_URL        = "BASE_URL"
-	TEST_MODE       = "TEST_MODE"
-	CLICKHOUSE_URL  = "CLICKHOUSE_URL"
-	CLICKHOUSE_USER = "CLICKHOUSE_USER"
-	CLICKHOUSE_DB   = "CLICKHOUSE_DB"
-	CLICKHOUSE_PASS = "CLICKHOUSE_PASS"
-	CLICKHOUSE_PORT = "CLICKHOUSE_PORT"
-	ENV_FILE        = ".env"
-
-	START_TIME = "start_time"
-	END_TIME   = "end_time"
+	SERVER_PORT               = "SERVER_PORT"
+	ALLOW_ORIGINS             = "ALLOW_ORIGINS"
+	BASE_URL                  = "BASE_URL"
+	TEST_MODE                 = "TEST_MODE"
+	CLICKHOUSE_URL            = "CLICKHOUSE_URL"
+	CLICKHOUSE_USER           = "CLICKHOUSE_USER"
+	CLICKHOUSE_DB             = "CLICKHOUSE_DB"
+	CLICKHOUSE_PASS           = "CLICKHOUSE_PASS"
+	CLICKHOUSE_PORT           = "CLICKHOUSE_PORT"
+	READ_ONLY                 = "READ_ONLY"
+	METRIC_TABLES             = "METRIC_TABLES"
+	SESSION_ID_STRATEGY       = "SESSION_ID_STRATEGY"
+	SESSION_ID_REGEX          = "SESSION_ID_REGEX"
+	METRIC_RETENTION_DAYS     = "METRIC_RETENTION_DAYS"
+	METRIC_RETENTION_INTERVAL = "METRIC_RETENTION_INTERVAL"
+	METRIC_KEY_ALLOWLIST      = "METRIC_KEY_ALLOWLIST"
+	METRIC_KEY_ALLOWLIST_MODE = "METRIC_KEY_ALLOWLIST_MODE"
+	STRICT_JSON               = "STRICT_JSON"
+	ENV_FILE                  = ".env"
+
+	START_TIME      = "start_time"
+	END_TIME        = "end_time"
 	INCLUDE_PROMPTS = "include_prompts"
+	INCLUDE_COUNTS  = "include_counts"
+	UPSERT          = "upsert"
 
-	SESSION_ID = "session_id"
-	SPAN_ID    = "span_id"
-	APP_NAME   = "app_name"
+	SESSION_ID   = "session_id"
+	SESSION_A    = "session_a"
+	SESSION_B    = "session_b"
+	SPAN_ID      = "span_id"
+	TRACE_ID     = "trace_id"
+	APP_NAME     = "app_name"
+	SERVICE_NAME = "service_name"
+	LIMIT        = "limit"
+	PAGE         = "page"
+	SORT         = "sort"
+	SCOPE        = "scope"
+	TYPED        = "typed"
+	KEY          = "key"
+	KEYS         = "keys"
+	AGG          = "agg"
+	PRETTY       = "pretty"
+	CURSOR       = "cursor"
+	EVENT_NAME   = "event_name"
+	FIELDS       = "fields"
 
 	METRIC_SCOPE_SESSION = "session"
 	METRIC_SCOPE_SPAN    = "span"
+
+	CONTENT_TYPE_NDJSON = "application/x-ndjson"
+
+	RESPONSE_ENVELOPE_HEADER = "X-Response-Envelope"
+
+	SORT_TIMESTAMP_ASC  = "timestamp_asc"
+	SORT_TIMESTAMP_DESC = "timestamp_desc"
+
+	METRIC_ROLLUP_SUM = "sum"
+	METRIC_ROLLUP_AVG = "avg"
+	METRIC_ROLLUP_MIN = "min"
+	METRIC_ROLLUP_MAX = "max"
+
+	METRIC_KEY_ALLOWLIST_MODE_REJECT = "reject"
+	METRIC_KEY_ALLOWLIST_MODE_DROP   = "drop"
+
+	SESSION_ID_STRATEGY_RAW   = "raw"
+	SESSION_ID_STRATEGY_SPLIT = "split"
+	SESSION_ID_STRATEGY_REGEX = "regex"
+)
+
+const (
+	ATTRIBUTE_KEYS_DEFAULT_LIMIT = 100
+	ATTRIBUTE_KEYS_MAX_LIMIT     = 1000
+
+	SESSIONS_EXIST_MAX_IDS = 1000
+
+	METRIC_MATRIX_MAX_KEYS = 50
+
+	SESSIONS_DEFAULT_LIMIT = 100
+	SESSIONS_MAX_LIMIT     = 1000
 )
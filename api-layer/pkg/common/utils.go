@@ -5,9 +5,12 @@ package common
 
 import (
 	"errors"
+	"fmt"
 	"math/rand"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
 
 	"time"
 
@@ -53,6 +56,19 @@ func GetEnvBool(key string, fallback bool) bool {
 	return boolValue
 }
 
+func GetEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, exists := os.LookupEnv(key)
+	if !exists {
+		return fallback
+	}
+	durationValue, err := time.ParseDuration(value)
+	if err != nil {
+		logger.Zap.Error("Error converting env var to duration", logger.Error(err), logger.String("key", key), logger.String("value", value))
+		return fallback
+	}
+	return durationValue
+}
+
 func LoadEnv() {
 	// Check if the .env file exists
 	if _, err := os.Stat(ENV_FILE); err == nil {
@@ -73,11 +89,80 @@ func ParseTime(timeString string) (timeParsed time.Time, err error) {
 	}
 
 	timeParsed, err = time.Parse(time.RFC3339, timeString)
-	// Check ISO 8601 UTC format
-	if err != nil || timeParsed.Location() != time.UTC {
-		logger.Zap.Error("Invalid date format, must be in ISO 8601 UTC format", logger.Error(err), logger.String("time", timeString))
-		return timeParsed, errors.New("invalid date format, must be in ISO 8601 UTC format")
+	// Check ISO 8601 format, any offset is accepted
+	if err != nil {
+		logger.Zap.Error("Invalid date format, must be in ISO 8601 format", logger.Error(err), logger.String("time", timeString))
+		return timeParsed, errors.New("invalid date format, must be in ISO 8601 format")
 	}
 
-	return timeParsed, nil
+	// Times are always handled in UTC internally
+	return timeParsed.UTC(), nil
+}
+
+var tableNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// IsValidScope reports whether scope is a known metric scope
+func IsValidScope(scope string) bool {
+	return scope == METRIC_SCOPE_SESSION || scope == METRIC_SCOPE_SPAN
+}
+
+// IsValidRollup reports whether agg is a supported metric roll-up aggregation
+func IsValidRollup(agg string) bool {
+	switch agg {
+	case METRIC_ROLLUP_SUM, METRIC_ROLLUP_AVG, METRIC_ROLLUP_MIN, METRIC_ROLLUP_MAX:
+		return true
+	}
+	return false
+}
+
+// ParseMetricTables parses a scope to table mapping such as "session=session_metrics,span=span_metrics"
+func ParseMetricTables(value string) (map[string]string, error) {
+	tables := make(map[string]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		scope, table, found := strings.Cut(entry, "=")
+		scope = strings.TrimSpace(scope)
+		table = strings.TrimSpace(table)
+		if !found {
+			return nil, fmt.Errorf("invalid metric table mapping %q, expected scope=table", entry)
+		}
+		if !IsValidScope(scope) {
+			return nil, fmt.Errorf("invalid metric scope %q", scope)
+		}
+		if !tableNameRegexp.MatchString(table) {
+			return nil, fmt.Errorf("invalid metric table name %q", table)
+		}
+		tables[scope] = table
+	}
+	return tables, nil
+}
+
+// ParseMetricKeyAllowlist parses the metric keys allowed per app name such as
+// "ml-service=accuracy|latency_ms,api-gateway=response_time"
+func ParseMetricKeyAllowlist(value string) (map[string][]string, error) {
+	allowlist := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		appName, keys, found := strings.Cut(entry, "=")
+		appName = strings.TrimSpace(appName)
+		if !found || appName == "" {
+			return nil, fmt.Errorf("invalid metric key allowlist entry %q, expected app=key1|key2", entry)
+		}
+		for _, key := range strings.Split(keys, "|") {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return nil, fmt.Errorf("invalid metric key allowlist entry %q, empty metric key", entry)
+			}
+			allowlist[appName] = append(allowlist[appName], key)
+		}
+	}
+	return allowlist, nil
 }
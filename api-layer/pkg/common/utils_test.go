@@ -0,0 +1,122 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTime(t *testing.T) {
+	t.Run("UTC time should be parsed as is", func(t *testing.T) {
+		parsed, err := ParseTime("2023-06-25T15:04:05Z")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2023, 6, 25, 15, 4, 5, 0, time.UTC), parsed)
+		assert.Equal(t, time.UTC, parsed.Location())
+	})
+
+	t.Run("Time with offset should be normalized to UTC", func(t *testing.T) {
+		parsed, err := ParseTime("2023-06-25T17:04:05+02:00")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2023, 6, 25, 15, 4, 5, 0, time.UTC), parsed)
+		assert.Equal(t, time.UTC, parsed.Location())
+	})
+
+	t.Run("Fractional seconds should be preserved", func(t *testing.T) {
+		parsed, err := ParseTime("2023-06-25T10:04:05.250-05:00")
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2023, 6, 25, 15, 4, 5, 250000000, time.UTC), parsed)
+	})
+
+	t.Run("Empty string should be rejected", func(t *testing.T) {
+		_, err := ParseTime("")
+		assert.EqualError(t, err, "date cannot be empty")
+	})
+
+	t.Run("Invalid strings should be rejected", func(t *testing.T) {
+		for _, value := range []string{"invalid", "2023-06-25", "2023-06-25 15:04:05", "2023-06-25T15:04:05"} {
+			_, err := ParseTime(value)
+			assert.Error(t, err, value)
+		}
+	})
+}
+
+func TestIsValidScope(t *testing.T) {
+	assert.True(t, IsValidScope(METRIC_SCOPE_SESSION))
+	assert.True(t, IsValidScope(METRIC_SCOPE_SPAN))
+	assert.False(t, IsValidScope("sesion"))
+	assert.False(t, IsValidScope(""))
+}
+
+func TestParseMetricTables(t *testing.T) {
+	t.Run("Empty value should return an empty mapping", func(t *testing.T) {
+		tables, err := ParseMetricTables("")
+		assert.NoError(t, err)
+		assert.Empty(t, tables)
+	})
+
+	t.Run("Mapping should be parsed per scope", func(t *testing.T) {
+		tables, err := ParseMetricTables(" session = session_metrics , span=archive.span_metrics")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"session": "session_metrics", "span": "archive.span_metrics"}, tables)
+	})
+
+	t.Run("Invalid entries should be rejected", func(t *testing.T) {
+		for _, value := range []string{"session", "sesion=metrics", "session=", "span=metrics; DROP TABLE otel_traces"} {
+			_, err := ParseMetricTables(value)
+			assert.Error(t, err, value)
+		}
+	})
+}
+
+func TestGetEnvDuration(t *testing.T) {
+	t.Run("Unset variable should return the fallback", func(t *testing.T) {
+		assert.Equal(t, time.Hour, GetEnvDuration("TEST_UNSET_DURATION", time.Hour))
+	})
+
+	t.Run("Valid duration should be parsed", func(t *testing.T) {
+		t.Setenv("TEST_DURATION", "15m")
+		assert.Equal(t, 15*time.Minute, GetEnvDuration("TEST_DURATION", time.Hour))
+	})
+
+	t.Run("Invalid duration should return the fallback", func(t *testing.T) {
+		t.Setenv("TEST_DURATION", "15")
+		assert.Equal(t, time.Hour, GetEnvDuration("TEST_DURATION", time.Hour))
+	})
+}
+
+func TestParseMetricKeyAllowlist(t *testing.T) {
+	t.Run("Empty value should return an empty allowlist", func(t *testing.T) {
+		allowlist, err := ParseMetricKeyAllowlist("")
+		assert.NoError(t, err)
+		assert.Empty(t, allowlist)
+	})
+
+	t.Run("Keys should be parsed per app", func(t *testing.T) {
+		allowlist, err := ParseMetricKeyAllowlist(" ml-service = accuracy | latency_ms , api-gateway=response_time")
+		assert.NoError(t, err)
+		assert.Equal(t, map[string][]string{
+			"ml-service":  {"accuracy", "latency_ms"},
+			"api-gateway": {"response_time"},
+		}, allowlist)
+	})
+
+	t.Run("Invalid entries should be rejected", func(t *testing.T) {
+		for _, value := range []string{"ml-service", "=accuracy", "ml-service=", "ml-service=accuracy||latency_ms"} {
+			_, err := ParseMetricKeyAllowlist(value)
+			assert.Error(t, err, value)
+		}
+	})
+}
+
+func TestIsValidRollup(t *testing.T) {
+	for _, agg := range []string{"sum", "avg", "min", "max"} {
+		assert.True(t, IsValidRollup(agg), agg)
+	}
+	for _, agg := range []string{"", "median", "SUM"} {
+		assert.False(t, IsValidRollup(agg), agg)
+	}
+}
@@ -4,6 +4,7 @@
 package clickhouse
 
 import (
+	"errors"
 	"net/url"
 	"strconv"
 	"time"
@@ -24,6 +25,10 @@ type ClickhouseService struct {
 	DB           string
 	clickhouseDB *gorm.DB
 	Handlers     handlers.Handler
+	// MetricTables maps a metric scope to its table, unmapped scopes use derived_metrics
+	MetricTables map[string]string
+	// SessionIDStrategy extracts session ids from span attributes in every session query
+	SessionIDStrategy handlers.SessionIDStrategy
 }
 
 func (cs *ClickhouseService) Init() error {
@@ -39,33 +44,100 @@ func (cs *ClickhouseService) Init() error {
 	}
 
 	cs.clickhouseDB.AutoMigrate(&models.Metric{})
+	for _, table := range cs.MetricTables {
+		cs.clickhouseDB.Table(table).AutoMigrate(&models.Metric{})
+	}
 	cs.Handlers = handlers.New(cs.clickhouseDB)
+	cs.Handlers.MetricTables = cs.MetricTables
+	cs.Handlers.SessionID = cs.SessionIDStrategy
 	return nil
 }
 
+// Ping implements the DataService interface, checking the ClickHouse connection
+func (cs *ClickhouseService) Ping() error {
+	if cs.clickhouseDB == nil {
+		return errors.New("clickhouse connection is not initialized")
+	}
+	sqlDB, err := cs.clickhouseDB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Ping()
+}
+
 // GetSessionIDSUnique implements the DataService interface
 func (cs *ClickhouseService) GetSessionIDSUnique(startTime, endTime time.Time) ([]models.SessionUniqueID, error) {
 	return cs.Handlers.GetSessionIDSUnique(startTime, endTime)
 }
 
+// GetSessionIDSUniqueWithPagination implements the DataService interface
+func (cs *ClickhouseService) GetSessionIDSUniqueWithPagination(startTime, endTime time.Time, page, limit int, nameFilter *string, cursor string) ([]models.SessionUniqueID, int, string, error) {
+	return cs.Handlers.GetSessionIDSUniqueWithPagination(startTime, endTime, page, limit, nameFilter, cursor)
+}
+
 // GetSessionIDSWithPrompts implements the DataService interface
 func (cs *ClickhouseService) GetSessionIDSWithPrompts(startTime, endTime time.Time) ([]models.SessionUniqueID, error) {
     return cs.Handlers.GetSessionIDSWithPrompts(startTime, endTime)
 }
 
+// GetSessionConversation implements the DataService interface
+func (cs *ClickhouseService) GetSessionConversation(sessionID string) ([]models.ConversationMessage, error) {
+	return cs.Handlers.GetSessionConversation(sessionID)
+}
+
+// GetSessionSpanCounts implements the DataService interface
+func (cs *ClickhouseService) GetSessionSpanCounts(sessionIDs []string) (map[string]models.SessionSpanCount, error) {
+	return cs.Handlers.GetSessionSpanCounts(sessionIDs)
+}
+
+// PurgeSession implements the DataService interface
+func (cs *ClickhouseService) PurgeSession(sessionID string) (models.PurgeResult, error) {
+	return cs.Handlers.PurgeSession(sessionID)
+}
+
+// CheckSessionsExist implements the DataService interface
+func (cs *ClickhouseService) CheckSessionsExist(sessionIDs []string) (present []string, missing []string, err error) {
+	return cs.Handlers.CheckSessionsExist(sessionIDs)
+}
+
 // AddMetric implements the DataService interface
 func (cs *ClickhouseService) AddMetric(metric models.Metric) (models.Metric, error) {
 	return cs.Handlers.AddMetric(metric)
 }
 
+// UpsertMetric implements the DataService interface
+func (cs *ClickhouseService) UpsertMetric(metric models.Metric) (models.Metric, error) {
+	return cs.Handlers.UpsertMetric(metric)
+}
+
 // GetMetricsBySessionIDAndScope implements the DataService interface
-func (cs *ClickhouseService) GetMetricsBySessionIdAndScope(sessionID string, scope string) ([]models.Metric, error) {
-	return cs.Handlers.GetMetricsBySessionIdAndScope(sessionID, scope)
+func (cs *ClickhouseService) GetMetricsBySessionIdAndScope(sessionID string, scope string, page, limit int, sort string) ([]models.Metric, error) {
+	return cs.Handlers.GetMetricsBySessionIdAndScope(sessionID, scope, page, limit, sort)
 }
 
 // GetMetricsBySpanIdAndScope implements the DataService interface
-func (cs *ClickhouseService) GetMetricsBySpanIdAndScope(spanID string, scope string) ([]models.Metric, error) {
-	return cs.Handlers.GetMetricsBySpanIdAndScope(spanID, scope)
+func (cs *ClickhouseService) GetMetricsBySpanIdAndScope(spanID string, scope string, page, limit int, sort string) ([]models.Metric, error) {
+	return cs.Handlers.GetMetricsBySpanIdAndScope(spanID, scope, page, limit, sort)
+}
+
+// RollupSpanMetricsToSession implements the DataService interface
+func (cs *ClickhouseService) RollupSpanMetricsToSession(sessionID, metricKey, agg string) (float64, error) {
+	return cs.Handlers.RollupSpanMetricsToSession(sessionID, metricKey, agg)
+}
+
+// GetDistinctMetricApps implements the DataService interface
+func (cs *ClickhouseService) GetDistinctMetricApps(startTime, endTime *time.Time) ([]models.AppInfo, error) {
+	return cs.Handlers.GetDistinctMetricApps(startTime, endTime)
+}
+
+// GetSpansWithMetrics implements the DataService interface
+func (cs *ClickhouseService) GetSpansWithMetrics(sessionID string, scope string) ([]models.SpanMetricCount, error) {
+	return cs.Handlers.GetSpansWithMetrics(sessionID, scope)
+}
+
+// GetMetricMatrix implements the DataService interface
+func (cs *ClickhouseService) GetMetricMatrix(keys []string, scope string) (models.MetricMatrix, error) {
+	return cs.Handlers.GetMetricMatrix(keys, scope)
 }
 
 // GetTracesBySessionID implements the DataService interface
@@ -73,6 +145,11 @@ func (cs *ClickhouseService) GetTracesBySessionID(sessionID string) ([]models.Ot
 	return cs.Handlers.GetTracesBySessionID(sessionID)
 }
 
+// StreamTracesBySessionID implements the DataService interface
+func (cs *ClickhouseService) StreamTracesBySessionID(sessionID string, fn func(models.OtelTraces) error) error {
+	return cs.Handlers.StreamTracesBySessionID(sessionID, fn)
+}
+
 // GetTracesBySessionIDs implements the DataService interface (batch)
 func (cs *ClickhouseService) GetTracesBySessionIDs(sessionIDs []string) (map[string][]models.OtelTraces, []string, error) {
 	return cs.Handlers.GetTracesBySessionIDs(sessionIDs)
@@ -82,3 +159,38 @@ func (cs *ClickhouseService) GetTracesBySessionIDs(sessionIDs []string) (map[str
 func (cs *ClickhouseService) GetSpanBySessionIDAndSpanID(sessionID string, spanID string) (models.OtelTraces, error) {
 	return cs.Handlers.GetSpanBySessionIDAndSpanID(sessionID, spanID)
 }
+
+// GetTraceStats implements the DataService interface
+func (cs *ClickhouseService) GetTraceStats(traceID string) (models.TraceStats, error) {
+	return cs.Handlers.GetTraceStats(traceID)
+}
+
+// GetSessionEvents implements the DataService interface
+func (cs *ClickhouseService) GetSessionEvents(sessionID string, eventName *string) ([]models.SpanEvent, error) {
+	return cs.Handlers.GetSessionEvents(sessionID, eventName)
+}
+
+// GetTraceAttributeKeys implements the DataService interface
+func (cs *ClickhouseService) GetTraceAttributeKeys(serviceName *string, startTime, endTime time.Time, limit int) ([]string, error) {
+	return cs.Handlers.GetTraceAttributeKeys(serviceName, startTime, endTime, limit)
+}
+
+// GetCallGraphEdges implements the DataService interface
+func (cs *ClickhouseService) GetCallGraphEdges(sessionID string) ([]models.CallGraphEdge, error) {
+	return cs.Handlers.GetCallGraphEdges(sessionID)
+}
+
+// GetTokenUsagePerSession implements the DataService interface
+func (cs *ClickhouseService) GetTokenUsagePerSession(startTime, endTime time.Time, page, limit int) ([]models.SessionTokenUsage, int, error) {
+	return cs.Handlers.GetTokenUsagePerSession(startTime, endTime, page, limit)
+}
+
+// GetGraphDeterminism implements the DataService interface
+func (cs *ClickhouseService) GetGraphDeterminism(appName string, startTime, endTime time.Time) (models.GraphDeterminism, error) {
+	return cs.Handlers.GetGraphDeterminism(appName, startTime, endTime)
+}
+
+// GetSpanDurationStats implements the DataService interface
+func (cs *ClickhouseService) GetSpanDurationStats(serviceName *string, startTime, endTime time.Time) ([]models.SpanDurationStat, error) {
+	return cs.Handlers.GetSpanDurationStats(serviceName, startTime, endTime)
+}
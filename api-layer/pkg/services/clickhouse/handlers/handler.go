@@ -3,12 +3,61 @@
 
 package handlers
 
-import "gorm.io/gorm"
+import (
+	"slices"
+	"sort"
+
+	"gorm.io/gorm"
+
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/models"
+)
 
 type Handler struct {
 	DB *gorm.DB
+	// MetricTables maps a metric scope to its table, unmapped scopes use derived_metrics
+	MetricTables map[string]string
+	// SessionID extracts session ids from span attributes, the zero value uses the raw attribute
+	SessionID SessionIDStrategy
 }
 
 func New(db *gorm.DB) Handler {
-	return Handler{db}
+	return Handler{DB: db}
+}
+
+// metricTable returns the table storing metrics of the given scope
+func (h Handler) metricTable(scope string) string {
+	if table, ok := h.MetricTables[scope]; ok && table != "" {
+		return table
+	}
+	return models.Metric{}.TableName()
+}
+
+// metricTableNames returns every table storing metrics, sorted by name
+func (h Handler) metricTableNames() []string {
+	names := []string{models.Metric{}.TableName()}
+	for _, table := range h.MetricTables {
+		if table != "" && !slices.Contains(names, table) {
+			names = append(names, table)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deleteRows counts then deletes the rows of table matching the condition and
+// returns the count. ClickHouse applies the delete as an asynchronous mutation,
+// so the rows can stay visible for a short time. A failed count is logged and
+// does not prevent the delete
+func (h Handler) deleteRows(table string, model interface{}, condition string, args ...interface{}) (int64, error) {
+	var count int64
+	if result := h.DB.Table(table).Where(condition, args...).Count(&count); result.Error != nil {
+		logger.Zap.Warn("Error counting rows to delete", logger.String("table", table), logger.Error(result.Error))
+	}
+
+	if result := h.DB.Table(table).Where(condition, args...).Delete(model); result.Error != nil {
+		logger.Zap.Error("Error deleting rows", logger.String("table", table), logger.Error(result.Error))
+		return count, result.Error
+	}
+	return count, nil
 }
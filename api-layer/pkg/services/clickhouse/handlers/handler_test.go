@@ -0,0 +1,1272 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	clickhousego "github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/clickhouse"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/models"
+)
+
+// errRecorded is returned by recordingConnPool for statements that would need a real database
+var errRecorded = errors.New("statement recorded")
+
+// recordingConnPool records the SQL sent by GORM instead of running it against ClickHouse
+type recordingConnPool struct {
+	statements []string
+	args       [][]interface{}
+	// failExec makes ExecContext fail for statements containing it
+	failExec string
+}
+
+func (p *recordingConnPool) PrepareContext(_ context.Context, query string) (*sql.Stmt, error) {
+	p.statements = append(p.statements, query)
+	return nil, errRecorded
+}
+
+func (p *recordingConnPool) ExecContext(_ context.Context, query string, _ ...interface{}) (sql.Result, error) {
+	p.statements = append(p.statements, query)
+	if p.failExec != "" && strings.Contains(query, p.failExec) {
+		return nil, errRecorded
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (p *recordingConnPool) QueryContext(_ context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	p.statements = append(p.statements, query)
+	p.args = append(p.args, args)
+	return nil, errRecorded
+}
+
+func (p *recordingConnPool) QueryRowContext(_ context.Context, query string, _ ...interface{}) *sql.Row {
+	p.statements = append(p.statements, query)
+	return nil
+}
+
+// newTestHandler returns a Handler whose statements are recorded instead of executed
+func newTestHandler(t *testing.T) (Handler, *recordingConnPool) {
+	pool := &recordingConnPool{}
+	db, err := gorm.Open(clickhouse.New(clickhouse.Config{
+		Conn:                      pool,
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Discard})
+	require.NoError(t, err)
+	return New(db), pool
+}
+
+// sessionPagesConn is a database/sql driver answering the session page queries
+// from a list of sessions sorted newest first. Keyset pages keep the sessions
+// whose (StartTimestamp, ID) tuple is below the bound cursor values, as
+// ClickHouse compares tuples, so the test runs the SQL arguments the handler
+// actually sends
+type sessionPagesConn struct {
+	t          *testing.T
+	sessions   []models.SessionUniqueID
+	statements []string
+}
+
+func (c *sessionPagesConn) Connect(context.Context) (driver.Conn, error) {
+	return c, nil
+}
+
+func (c *sessionPagesConn) Driver() driver.Driver {
+	return nil
+}
+
+func (c *sessionPagesConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errRecorded
+}
+
+func (c *sessionPagesConn) Close() error {
+	return nil
+}
+
+func (c *sessionPagesConn) Begin() (driver.Tx, error) {
+	return nil, errRecorded
+}
+
+func (c *sessionPagesConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.statements = append(c.statements, query)
+	if strings.Contains(query, "count(*)") {
+		return &sliceRows{columns: []string{"count"}, values: [][]driver.Value{{int64(len(c.sessions))}}}, nil
+	}
+
+	// Arguments are start_time, end_time, then the cursor values and the
+	// limit, or the limit and the offset when it is not 0
+	rows := c.sessions
+	var limit int64
+	if strings.Contains(query, "HAVING") {
+		require.Len(c.t, args, 5)
+		after, err := time.Parse(time.RFC3339Nano, args[2].Value.(string))
+		require.NoError(c.t, err)
+		id := args[3].Value.(string)
+		rows = nil
+		for _, session := range c.sessions {
+			timestamp, err := time.Parse(time.RFC3339Nano, session.StartTimestamp)
+			require.NoError(c.t, err)
+			if timestamp.Before(after) || (timestamp.Equal(after) && session.ID < id) {
+				rows = append(rows, session)
+			}
+		}
+		limit = args[4].Value.(int64)
+	} else {
+		limit = args[2].Value.(int64)
+		if len(args) == 4 {
+			rows = rows[min(int(args[3].Value.(int64)), len(rows)):]
+		}
+	}
+	if int64(len(rows)) > limit {
+		rows = rows[:limit]
+	}
+
+	result := &sliceRows{columns: []string{"ID", "StartTimestamp"}}
+	for _, session := range rows {
+		result.values = append(result.values, []driver.Value{session.ID, session.StartTimestamp})
+	}
+	return result, nil
+}
+
+// sliceRows returns fixed values as driver rows
+type sliceRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *sliceRows) Columns() []string {
+	return r.columns
+}
+
+func (r *sliceRows) Close() error {
+	return nil
+}
+
+func (r *sliceRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// metricTableConn is a database/sql driver holding the rows of one metric
+// session and scope in memory. It answers the upsert SELECT with the rows of
+// the bound span sharing a bound key, applies the ALTER UPDATE and DELETE
+// mutations and the INSERT, and records every statement with the ClickHouse
+// settings of its context
+type metricTableConn struct {
+	t          *testing.T
+	rows       []metricRow
+	statements []string
+	settings   []string
+}
+
+type metricRow struct {
+	id      string
+	spanID  string
+	metrics string
+}
+
+func (c *metricTableConn) Connect(context.Context) (driver.Conn, error) {
+	return c, nil
+}
+
+func (c *metricTableConn) Driver() driver.Driver {
+	return nil
+}
+
+func (c *metricTableConn) Close() error {
+	return nil
+}
+
+// Begin serves the transaction GORM wraps writes in, ClickHouse has none
+func (c *metricTableConn) Begin() (driver.Tx, error) {
+	return noTx{}, nil
+}
+
+type noTx struct{}
+
+func (noTx) Commit() error {
+	return nil
+}
+
+func (noTx) Rollback() error {
+	return nil
+}
+
+func (c *metricTableConn) record(ctx context.Context, query string) {
+	c.statements = append(c.statements, query)
+	var settings string
+	clickhousego.Context(ctx, func(options *clickhousego.QueryOptions) error {
+		settings = fmt.Sprintf("%v", options)
+		return nil
+	})
+	c.settings = append(c.settings, settings)
+}
+
+func (c *metricTableConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	c.record(ctx, query)
+	require.Contains(c.t, query, "arrayExists(k -> k IN")
+
+	// Arguments are the session, span and scope, then the keys
+	result := &sliceRows{columns: []string{"ID", "Metrics"}}
+	for _, row := range c.rows {
+		if row.spanID != args[1].Value.(string) {
+			continue
+		}
+		keys, err := models.JSONRawMessage(row.metrics).Keys()
+		require.NoError(c.t, err)
+		for _, arg := range args[3:] {
+			if slices.Contains(keys, arg.Value.(string)) {
+				result.values = append(result.values, []driver.Value{row.id, row.metrics})
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+func (c *metricTableConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.record(ctx, query)
+	switch {
+	case strings.Contains(query, " UPDATE "):
+		for i := range c.rows {
+			if c.rows[i].id == args[1].Value.(string) {
+				c.rows[i].metrics = args[0].Value.(string)
+			}
+		}
+	case strings.Contains(query, " DELETE "):
+		c.rows = slices.DeleteFunc(c.rows, func(row metricRow) bool {
+			return slices.ContainsFunc(args, func(arg driver.NamedValue) bool { return arg.Value == row.id })
+		})
+	default:
+		c.t.Fatalf("unexpected statement %s", query)
+	}
+	return driver.RowsAffected(0), nil
+}
+
+// Prepare serves the INSERT, which GORM runs as a prepared statement
+func (c *metricTableConn) Prepare(query string) (driver.Stmt, error) {
+	c.record(context.Background(), query)
+	require.True(c.t, strings.HasPrefix(query, "INSERT INTO"), query)
+	columns := strings.Split(query[strings.Index(query, "(")+1:strings.Index(query, ")")], ",")
+	for i, column := range columns {
+		columns[i] = strings.Trim(column, "` ")
+	}
+	return &metricInsertStmt{conn: c, columns: columns}, nil
+}
+
+// metricInsertStmt appends the inserted metrics to the rows of its conn
+type metricInsertStmt struct {
+	conn    *metricTableConn
+	columns []string
+}
+
+func (s *metricInsertStmt) Close() error {
+	return nil
+}
+
+func (s *metricInsertStmt) NumInput() int {
+	return -1
+}
+
+func (s *metricInsertStmt) Exec(args []driver.Value) (driver.Result, error) {
+	values := map[string]driver.Value{}
+	for i, column := range s.columns {
+		values[column] = args[i]
+	}
+	s.conn.rows = append(s.conn.rows, metricRow{
+		id:      values["ID"].(string),
+		spanID:  values["SpanId"].(string),
+		metrics: values["Metrics"].(string),
+	})
+	return driver.RowsAffected(1), nil
+}
+
+func (s *metricInsertStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errRecorded
+}
+
+func TestMetricTable(t *testing.T) {
+	t.Run("Unmapped scopes should use derived_metrics", func(t *testing.T) {
+		h, _ := newTestHandler(t)
+		assert.Equal(t, "derived_metrics", h.metricTable("session"))
+		assert.Equal(t, "derived_metrics", h.metricTable("span"))
+	})
+
+	t.Run("Mapped scopes should use their table", func(t *testing.T) {
+		h, _ := newTestHandler(t)
+		h.MetricTables = map[string]string{"span": "span_metrics"}
+		assert.Equal(t, "derived_metrics", h.metricTable("session"))
+		assert.Equal(t, "span_metrics", h.metricTable("span"))
+	})
+}
+
+func TestMetricQueriesTargetScopeTable(t *testing.T) {
+	tables := map[string]string{"session": "session_metrics", "span": "span_metrics"}
+
+	t.Run("Session metrics should be read from the session table", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = tables
+
+		_, err := h.GetMetricsBySessionIdAndScope("session_abc123", "session", 0, 0, "timestamp_desc")
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "FROM `session_metrics`")
+	})
+
+	t.Run("Span metrics should be read from the span table", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = tables
+
+		_, err := h.GetMetricsBySpanIdAndScope("span_abc123", "span", 0, 0, "timestamp_desc")
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "FROM `span_metrics`")
+	})
+
+	t.Run("Metrics should be written to the table of their scope", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = tables
+
+		_, err := h.AddMetric(testMetric("span"))
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "INSERT INTO `span_metrics`")
+	})
+
+	t.Run("Metrics should be written to derived_metrics without a mapping", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, err := h.AddMetric(testMetric("session"))
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "INSERT INTO `derived_metrics`")
+	})
+}
+
+func TestUpsertMetric(t *testing.T) {
+	t.Run("Upsert should first read the stored rows sharing a key", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = map[string]string{"span": "span_metrics"}
+
+		_, err := h.UpsertMetric(testMetric("span"))
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "SELECT ID, Metrics FROM `span_metrics`")
+		assert.Contains(t, pool.statements[0], "WHERE SessionId = ? AND SpanId = ? AND Scope = ? AND arrayExists(k -> k IN (?), JSONExtractKeys(Metrics))")
+		assert.Equal(t, []interface{}{"session_ghi789", "span_abc123", "span", "accuracy"}, pool.args[0])
+	})
+
+	t.Run("Upsert without a session or scope, or a span metric without a span, should be rejected", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		metric := testMetric("session")
+		metric.SessionId = nil
+		_, err := h.UpsertMetric(metric)
+		assert.ErrorIs(t, err, models.ErrIncompleteUpsert)
+
+		_, err = h.UpsertMetric(testMetric(""))
+		assert.ErrorIs(t, err, models.ErrIncompleteUpsert)
+
+		metric = testMetric("span")
+		metric.SpanId = nil
+		_, err = h.UpsertMetric(metric)
+		assert.ErrorIs(t, err, models.ErrIncompleteUpsert)
+		assert.Empty(t, pool.statements)
+	})
+
+	t.Run("Session upsert without a span should match the empty span", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		metric := testMetric("session")
+		metric.SpanId = nil
+		_, err := h.UpsertMetric(metric)
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Equal(t, []interface{}{"session_ghi789", "", "session", "accuracy"}, pool.args[0])
+	})
+
+	t.Run("Upsert plan should rewrite the other keys and delete emptied rows", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		rewrites := []models.Metric{{ID: stringPtr("metric_001"), Metrics: jsonPtr(`{"latency_ms":120}`)}}
+		assert.NoError(t, h.applyUpsertPlan("span_metrics", rewrites, []string{"metric_002"}))
+		require.Len(t, pool.statements, 2)
+		assert.Contains(t, pool.statements[0], "ALTER TABLE `span_metrics` UPDATE `Metrics`=? WHERE ID = ?")
+		assert.Contains(t, pool.statements[1], "ALTER TABLE `span_metrics` DELETE WHERE ID IN (?)")
+	})
+
+	t.Run("Insert then upsert should keep a single current row per key", func(t *testing.T) {
+		conn := &metricTableConn{t: t, rows: []metricRow{
+			{id: "metric_001", spanID: "", metrics: `{"accuracy":"0.95","latency_ms":120}`},
+			{id: "metric_002", spanID: "", metrics: `{"accuracy":"0.90"}`},
+			{id: "metric_003", spanID: "span_abc123", metrics: `{"accuracy":"0.50"}`},
+		}}
+		db, err := gorm.Open(clickhouse.New(clickhouse.Config{
+			Conn:                      sql.OpenDB(conn),
+			SkipInitializeWithVersion: true,
+		}), &gorm.Config{Logger: logger.Discard})
+		require.NoError(t, err)
+		h := New(db)
+
+		metric := testMetric("session")
+		metric.SpanId = nil
+		metric.Metrics = jsonPtr(`{"accuracy":"0.97"}`)
+		created, err := h.UpsertMetric(metric)
+		require.NoError(t, err)
+
+		require.Len(t, conn.statements, 4)
+		assert.Contains(t, conn.statements[0], "SELECT ID, Metrics FROM `derived_metrics` WHERE SessionId = ? AND SpanId = ? AND Scope = ?")
+		assert.Contains(t, conn.statements[1], "ALTER TABLE `derived_metrics` UPDATE `Metrics`=? WHERE ID = ?")
+		assert.Contains(t, conn.settings[1], "mutations_sync:1")
+		assert.Contains(t, conn.statements[2], "ALTER TABLE `derived_metrics` DELETE WHERE ID IN (?)")
+		assert.Contains(t, conn.settings[2], "mutations_sync:1")
+		assert.Contains(t, conn.statements[3], "INSERT INTO `derived_metrics`")
+
+		// The other key of the first write is kept, the replaced value is gone
+		// and the span metric sharing the key is untouched
+		assert.Equal(t, []metricRow{
+			{id: "metric_001", spanID: "", metrics: `{"latency_ms":120}`},
+			{id: "metric_003", spanID: "span_abc123", metrics: `{"accuracy":"0.50"}`},
+			{id: *created.ID, spanID: "", metrics: `{"accuracy":"0.97"}`},
+		}, conn.rows)
+	})
+
+	t.Run("Upsert with invalid metrics JSON should not touch the database", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		metric := testMetric("session")
+		invalid := models.JSONRawMessage(`not json`)
+		metric.Metrics = &invalid
+
+		_, err := h.UpsertMetric(metric)
+		assert.Error(t, err)
+		assert.Empty(t, pool.statements)
+	})
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+func jsonPtr(s string) *models.JSONRawMessage {
+	j := models.JSONRawMessage(s)
+	return &j
+}
+
+func testMetric(scope string) models.Metric {
+	spanID := "span_abc123"
+	traceID := "trace_def456"
+	sessionID := "session_ghi789"
+	appName := "ml-service"
+	appID := "app-001"
+	metrics := models.JSONRawMessage(`{"accuracy":"0.95"}`)
+	return models.Metric{
+		SpanId:    &spanID,
+		TraceId:   &traceID,
+		SessionId: &sessionID,
+		Metrics:   &metrics,
+		AppName:   &appName,
+		AppId:     &appID,
+		Scope:     &scope,
+	}
+}
+
+func TestSessionIDStrategy(t *testing.T) {
+	t.Run("Invalid strategies should be rejected", func(t *testing.T) {
+		_, err := NewSessionIDStrategy("prefix", "")
+		assert.Error(t, err)
+		_, err = NewSessionIDStrategy("regex", "")
+		assert.Error(t, err)
+		_, err = NewSessionIDStrategy("regex", "(")
+		assert.Error(t, err)
+	})
+
+	tests := []struct {
+		name     string
+		strategy string
+		regex    string
+		raw      string
+		expected string
+		column   string
+	}{
+		{
+			name:     "raw keeps the attribute",
+			strategy: "raw",
+			raw:      "tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8",
+			expected: "tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8",
+			column:   "SpanAttributes['session.id']",
+		},
+		{
+			name:     "split keeps the second part",
+			strategy: "split",
+			raw:      "tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8",
+			expected: "78e610a0-b3f3-4feb-93bd-ea314b83feb8",
+			column:   "splitByChar('_', SpanAttributes['session.id'])[2]",
+		},
+		{
+			name:     "split without separator returns empty",
+			strategy: "split",
+			raw:      "78e610a0",
+			expected: "",
+			column:   "splitByChar('_', SpanAttributes['session.id'])[2]",
+		},
+		{
+			name:     "regex keeps the first capture group",
+			strategy: "regex",
+			regex:    `^[a-z0-9-]+_(.+)$`,
+			raw:      "tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8",
+			expected: "78e610a0-b3f3-4feb-93bd-ea314b83feb8",
+			column:   `extract(SpanAttributes['session.id'], '^[a-z0-9-]+_(.+)$')`,
+		},
+		{
+			name:     "regex without group keeps the whole match",
+			strategy: "regex",
+			regex:    `[0-9a-f]{8}-[0-9a-f-]+`,
+			raw:      "tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8",
+			expected: "78e610a0-b3f3-4feb-93bd-ea314b83feb8",
+			column:   `extract(SpanAttributes['session.id'], '[0-9a-f]{8}-[0-9a-f-]+')`,
+		},
+		{
+			name:     "regex quotes are escaped in the column",
+			strategy: "regex",
+			regex:    `session'(\d+)`,
+			raw:      "session'42",
+			expected: "42",
+			column:   `extract(SpanAttributes['session.id'], 'session\'(\\d+)')`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strategy, err := NewSessionIDStrategy(tt.strategy, tt.regex)
+			require.NoError(t, err)
+			assert.Equal(t, tt.strategy, strategy.Name())
+			assert.Equal(t, tt.regex, strategy.Regex())
+			assert.Equal(t, tt.expected, strategy.Extract(tt.raw))
+			assert.Equal(t, tt.column, strategy.Column())
+		})
+	}
+}
+
+func TestSessionQueriesUseSessionIDStrategy(t *testing.T) {
+	t.Run("Zero value should list raw ids and look them up by suffix", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, _ = h.GetSessionIDSUnique(time.Now().Add(-time.Hour), time.Now())
+		_, _ = h.GetTracesBySessionID("78e610a0")
+		require.Len(t, pool.statements, 2)
+		assert.Contains(t, pool.statements[0], "SpanAttributes['session.id'] AS ID")
+		assert.Contains(t, pool.statements[0], "GROUP BY SpanAttributes['session.id']")
+		assert.Contains(t, pool.statements[1], "SpanAttributes['session.id'] LIKE ?")
+	})
+
+	t.Run("Split strategy should be used for listing and lookups", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.SessionID, _ = NewSessionIDStrategy("split", "")
+
+		_, _ = h.GetSessionIDSWithPrompts(time.Now().Add(-time.Hour), time.Now())
+		_, _ = h.GetSpanBySessionIDAndSpanID("78e610a0", "span_abc123")
+		_, _, _ = h.GetTracesBySessionIDs([]string{"78e610a0"})
+		require.Len(t, pool.statements, 3)
+		assert.Contains(t, pool.statements[0], "splitByChar('_', SpanAttributes['session.id'])[2] AS ID")
+		assert.Contains(t, pool.statements[0], "GROUP BY splitByChar('_', SpanAttributes['session.id'])[2]")
+		assert.Contains(t, pool.statements[1], "splitByChar('_', SpanAttributes['session.id'])[2] = ?")
+		assert.Contains(t, pool.statements[2], "splitByChar('_', SpanAttributes['session.id'])[2] IN (?)")
+	})
+
+	t.Run("Name filter should match the id extracted by the strategy", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.SessionID, _ = NewSessionIDStrategy("split", "")
+		nameFilter := "78e6"
+
+		_, _, _, _ = h.GetSessionIDSUniqueWithPagination(time.Now().Add(-time.Hour), time.Now(), 0, 10, &nameFilter, "")
+		_, _, _ = h.GetSessionIDSWithPromptsWithPagination(time.Now().Add(-time.Hour), time.Now(), 0, 10, &nameFilter)
+		require.Len(t, pool.statements, 2)
+		for _, statement := range pool.statements {
+			assert.Contains(t, statement, "splitByChar('_', SpanAttributes['session.id'])[2] LIKE ?")
+			assert.NotContains(t, statement, "SpanAttributes['session.id'] LIKE ?")
+		}
+	})
+
+	t.Run("Regex strategy should be used for listing, lookups and name filters", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.SessionID, _ = NewSessionIDStrategy("regex", `^[a-z0-9-]+_(.+)$`)
+		nameFilter := "78e6"
+		column := `extract(SpanAttributes['session.id'], '^[a-z0-9-]+_(.+)$')`
+
+		_, _ = h.GetSessionIDSUnique(time.Now().Add(-time.Hour), time.Now())
+		_, _ = h.GetTracesBySessionID("78e610a0-b3f3-4feb-93bd-ea314b83feb8")
+		_, _, _, _ = h.GetSessionIDSUniqueWithPagination(time.Now().Add(-time.Hour), time.Now(), 0, 10, &nameFilter, "")
+		require.Len(t, pool.statements, 3)
+		assert.Contains(t, pool.statements[0], column+" AS ID")
+		assert.Contains(t, pool.statements[0], "GROUP BY "+column)
+		assert.Contains(t, pool.statements[1], column+" = ?")
+		assert.Equal(t, []interface{}{"78e610a0-b3f3-4feb-93bd-ea314b83feb8"}, pool.args[1])
+		assert.Contains(t, pool.statements[2], column+" LIKE ?")
+		assert.Contains(t, pool.args[2], "78e6%")
+
+		assert.Equal(t, "78e610a0-b3f3-4feb-93bd-ea314b83feb8", h.SessionID.Extract("tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8"))
+	})
+}
+
+func TestMetricQueriesPagination(t *testing.T) {
+	t.Run("Metrics should be sorted by timestamp descending without paging by default", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, _ = h.GetMetricsBySessionIdAndScope("session_abc123", "session", 0, 0, "timestamp_desc")
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "ORDER BY Timestamp DESC")
+		assert.NotContains(t, pool.statements[0], "LIMIT")
+		assert.NotContains(t, pool.statements[0], "OFFSET")
+	})
+
+	t.Run("Metrics should be sorted by timestamp ascending when requested", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, _ = h.GetMetricsBySpanIdAndScope("span_abc123", "span", 0, 0, "timestamp_asc")
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "ORDER BY Timestamp ASC")
+	})
+
+	t.Run("First page should start at offset zero", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, _ = h.GetMetricsBySessionIdAndScope("session_abc123", "session", 0, 20, "timestamp_desc")
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "LIMIT ?")
+		assert.NotContains(t, pool.statements[0], "OFFSET")
+		assert.Equal(t, []interface{}{"session_abc123", "session", 20}, pool.args[0])
+	})
+
+	t.Run("Later pages should skip the previous pages", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, _ = h.GetMetricsBySpanIdAndScope("span_abc123", "span", 3, 20, "timestamp_desc")
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "LIMIT ? OFFSET ?")
+		assert.Equal(t, []interface{}{"span_abc123", "span", 20, 60}, pool.args[0])
+	})
+}
+
+func TestMetricRetention(t *testing.T) {
+	t.Run("Cutoff should be the given number of days before now in UTC", func(t *testing.T) {
+		now := time.Date(2024, 3, 10, 12, 30, 0, 0, time.FixedZone("CET", 3600))
+		assert.Equal(t, time.Date(2024, 2, 9, 11, 30, 0, 0, time.UTC), RetentionCutoff(now, 30))
+		assert.Equal(t, time.Date(2024, 3, 10, 11, 30, 0, 0, time.UTC), RetentionCutoff(now, 0))
+	})
+
+	t.Run("Expired metrics should be counted and deleted from every metric table", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = map[string]string{"session": "session_metrics", "span": "derived_metrics"}
+		cutoff := time.Date(2024, 2, 9, 11, 30, 0, 0, time.UTC)
+
+		_, err := h.DeleteMetricsBefore(cutoff)
+		assert.NoError(t, err)
+		require.Len(t, pool.statements, 4)
+		assert.Contains(t, pool.statements[0], "SELECT count(*) FROM `derived_metrics` WHERE Timestamp < ?")
+		assert.Contains(t, pool.statements[1], "ALTER TABLE `derived_metrics` DELETE WHERE Timestamp < ?")
+		assert.Equal(t, []interface{}{cutoff}, pool.args[0])
+		assert.Contains(t, pool.statements[2], "SELECT count(*) FROM `session_metrics` WHERE Timestamp < ?")
+		assert.Contains(t, pool.statements[3], "ALTER TABLE `session_metrics` DELETE WHERE Timestamp < ?")
+	})
+
+	t.Run("A failed table should not stop retention on the others", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = map[string]string{"session": "session_metrics", "span": "span_metrics"}
+		pool.failExec = "ALTER TABLE `derived_metrics`"
+
+		_, err := h.DeleteMetricsBefore(time.Date(2024, 2, 9, 11, 30, 0, 0, time.UTC))
+		assert.ErrorIs(t, err, errRecorded)
+		assert.ErrorContains(t, err, "derived_metrics")
+		assert.NotContains(t, err.Error(), "session_metrics")
+		assert.NotContains(t, err.Error(), "span_metrics")
+
+		require.Len(t, pool.statements, 6)
+		assert.Contains(t, pool.statements[1], "ALTER TABLE `derived_metrics` DELETE WHERE Timestamp < ?")
+		assert.Contains(t, pool.statements[3], "ALTER TABLE `session_metrics` DELETE WHERE Timestamp < ?")
+		assert.Contains(t, pool.statements[5], "ALTER TABLE `span_metrics` DELETE WHERE Timestamp < ?")
+	})
+}
+
+func TestGetDistinctMetricApps(t *testing.T) {
+	t.Run("Apps should be read from every metric table", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, err := h.GetDistinctMetricApps(nil, nil)
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "SELECT DISTINCT AppName, AppId FROM `derived_metrics`")
+		assert.NotContains(t, pool.statements[0], "WHERE")
+	})
+
+	t.Run("Time range should filter on the metric timestamp", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		startTime := time.Date(2023, 6, 25, 15, 4, 5, 0, time.UTC)
+		endTime := time.Date(2023, 6, 25, 18, 4, 5, 0, time.UTC)
+
+		_, _ = h.GetDistinctMetricApps(&startTime, &endTime)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "WHERE Timestamp >= ? AND Timestamp <= ?")
+		assert.Equal(t, []interface{}{startTime, endTime}, pool.args[0])
+	})
+}
+
+func TestGetSessionSpanCounts(t *testing.T) {
+	t.Run("No sessions should not query", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		counts, err := h.GetSessionSpanCounts(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, counts)
+		assert.Empty(t, pool.statements)
+	})
+
+	t.Run("Counts should be grouped by session", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, err := h.GetSessionSpanCounts([]string{"session_abc123", "session_def456"})
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "COUNT(*) AS SpanCount")
+		assert.Contains(t, pool.statements[0], "countIf(StatusCode IN ('Error', 'STATUS_CODE_ERROR')) AS ErrorCount")
+		assert.Contains(t, pool.statements[0], "SpanAttributes['session.id'] IN (?,?)")
+		assert.Contains(t, pool.statements[0], "GROUP BY SpanAttributes['session.id']")
+	})
+}
+
+func TestGetSessionConversation(t *testing.T) {
+	t.Run("Only gen_ai spans of the session should be read in order", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, err := h.GetSessionConversation("session_abc123")
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "SELECT Timestamp, SpanId, SpanAttributes FROM `otel_traces`")
+		assert.Contains(t, pool.statements[0], "WHERE (arrayExists(k -> startsWith(k, 'gen_ai.prompt.') OR startsWith(k, 'gen_ai.completion.'), mapKeys(SpanAttributes))) AND SpanAttributes['session.id'] LIKE ?")
+		assert.Contains(t, pool.statements[0], "ORDER BY Timestamp ASC")
+		assert.Equal(t, []interface{}{"%session_abc123"}, pool.args[0])
+	})
+
+	t.Run("Short conversation should be rebuilt without repeated history", func(t *testing.T) {
+		at := func(second int) time.Time { return time.Date(2023, 6, 25, 15, 30, second, 0, time.UTC) }
+		spans := []models.OtelTraces{
+			{
+				Timestamp: at(0), SpanId: "span_1",
+				SpanAttributes: models.AttributeMap{
+					"gen_ai.prompt.0.role":        "system",
+					"gen_ai.prompt.0.content":     "You are a travel agent.",
+					"gen_ai.prompt.1.role":        "user",
+					"gen_ai.prompt.1.content":     "Book a flight to Paris.",
+					"gen_ai.completion.0.role":    "assistant",
+					"gen_ai.completion.0.content": "Which date?",
+					"gen_ai.request.model":        "gpt-4o",
+				},
+			},
+			{
+				Timestamp: at(10), SpanId: "span_2",
+				SpanAttributes: models.AttributeMap{
+					"gen_ai.prompt.0.role":        "system",
+					"gen_ai.prompt.0.content":     "You are a travel agent.",
+					"gen_ai.prompt.1.role":        "user",
+					"gen_ai.prompt.1.content":     "Book a flight to Paris.",
+					"gen_ai.prompt.2.role":        "assistant",
+					"gen_ai.prompt.2.content":     "Which date?",
+					"gen_ai.prompt.10.role":       "user",
+					"gen_ai.prompt.10.content":    "Next Monday.",
+					"gen_ai.completion.0.role":    "assistant",
+					"gen_ai.completion.0.content": "Booked for Monday.",
+				},
+			},
+		}
+
+		messages := conversation(spans)
+		summary := make([]string, len(messages))
+		for i, message := range messages {
+			summary[i] = message.SpanId + "/" + message.Role + ": " + message.Content
+		}
+		assert.Equal(t, []string{
+			"span_1/system: You are a travel agent.",
+			"span_1/user: Book a flight to Paris.",
+			"span_1/assistant: Which date?",
+			"span_2/user: Next Monday.",
+			"span_2/assistant: Booked for Monday.",
+		}, summary)
+		assert.Equal(t, at(10), messages[3].Timestamp)
+	})
+
+	t.Run("Spans without gen_ai messages should return an empty conversation", func(t *testing.T) {
+		spans := []models.OtelTraces{{SpanId: "span_1", SpanAttributes: models.AttributeMap{"gen_ai.request.model": "gpt-4o"}}}
+		assert.Equal(t, []models.ConversationMessage{}, conversation(spans))
+		assert.Equal(t, []models.ConversationMessage{}, conversation(nil))
+	})
+}
+
+func TestCheckSessionsExist(t *testing.T) {
+	t.Run("No sessions should not query", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		present, missing, err := h.CheckSessionsExist(nil)
+		assert.NoError(t, err)
+		assert.Empty(t, present)
+		assert.Empty(t, missing)
+		assert.Empty(t, pool.statements)
+	})
+
+	t.Run("Sessions should be checked with a single grouped query", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.SessionID, _ = NewSessionIDStrategy("split", "")
+
+		_, _, err := h.CheckSessionsExist([]string{"session_abc123", "session_missing"})
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "SELECT splitByChar('_', SpanAttributes['session.id'])[2] AS ID FROM `otel_traces`")
+		assert.Contains(t, pool.statements[0], "WHERE splitByChar('_', SpanAttributes['session.id'])[2] IN (?,?)")
+		assert.Contains(t, pool.statements[0], "GROUP BY splitByChar('_', SpanAttributes['session.id'])[2]")
+	})
+
+	t.Run("Present and missing sessions should keep the requested order", func(t *testing.T) {
+		present, missing := splitSessionIDs(
+			[]string{"session_c", "session_a", "session_missing", "session_a", "session_b"},
+			[]string{"session_a", "session_b", "session_c"},
+		)
+		assert.Equal(t, []string{"session_c", "session_a", "session_b"}, present)
+		assert.Equal(t, []string{"session_missing"}, missing)
+	})
+
+	t.Run("Missing sessions only should return an empty present list", func(t *testing.T) {
+		present, missing := splitSessionIDs([]string{"session_missing"}, nil)
+		assert.Equal(t, []string{}, present)
+		assert.Equal(t, []string{"session_missing"}, missing)
+	})
+}
+
+func TestRollupSpanMetricsToSession(t *testing.T) {
+	for agg, function := range map[string]string{"sum": "sumOrNull", "avg": "avgOrNull", "min": "minOrNull", "max": "maxOrNull"} {
+		t.Run("Aggregation "+agg+" should use "+function, func(t *testing.T) {
+			h, pool := newTestHandler(t)
+			h.MetricTables = map[string]string{"span": "span_metrics"}
+
+			_, err := h.RollupSpanMetricsToSession("session_abc123", "latency_ms", agg)
+			assert.ErrorIs(t, err, errRecorded)
+			require.Len(t, pool.statements, 1)
+			assert.Contains(t, pool.statements[0], "SELECT "+function+"(toFloat64OrNull(trim(BOTH '\"' FROM JSONExtractRaw(Metrics, ?)))) AS Value FROM `span_metrics`")
+			assert.Contains(t, pool.statements[0], "WHERE SessionId = ? AND Scope = ?")
+			assert.Equal(t, []interface{}{"latency_ms", "session_abc123", "span"}, pool.args[0])
+		})
+	}
+
+	t.Run("Unknown aggregation should be rejected without querying", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, err := h.RollupSpanMetricsToSession("session_abc123", "latency_ms", "median")
+		assert.Error(t, err)
+		assert.Empty(t, pool.statements)
+	})
+}
+
+func TestPurgeSession(t *testing.T) {
+	t.Run("Every metric table then traces should be purged", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = map[string]string{"span": "span_metrics"}
+
+		result, err := h.PurgeSession("session_abc123")
+		assert.NoError(t, err)
+		assert.Equal(t, "session_abc123", result.SessionID)
+		assert.Empty(t, result.Failed)
+		assert.Equal(t, map[string]int64{"otel_traces": 0, "derived_metrics": 0, "span_metrics": 0}, result.Deleted)
+
+		require.Len(t, pool.statements, 6)
+		assert.Contains(t, pool.statements[0], "SELECT count(*) FROM `derived_metrics` WHERE SessionId = ?")
+		assert.Contains(t, pool.statements[1], "ALTER TABLE `derived_metrics` DELETE WHERE SessionId = ?")
+		assert.Contains(t, pool.statements[2], "SELECT count(*) FROM `span_metrics` WHERE SessionId = ?")
+		assert.Contains(t, pool.statements[3], "ALTER TABLE `span_metrics` DELETE WHERE SessionId = ?")
+		assert.Contains(t, pool.statements[4], "SELECT count(*) FROM `otel_traces` WHERE SpanAttributes['session.id'] = ?")
+		assert.Contains(t, pool.statements[5], "ALTER TABLE `otel_traces` DELETE WHERE SpanAttributes['session.id'] = ?")
+		assert.Equal(t, []interface{}{"session_abc123"}, pool.args[0])
+	})
+
+	t.Run("A failed table should not stop the purge of the others", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = map[string]string{"span": "span_metrics"}
+		pool.failExec = "ALTER TABLE `derived_metrics`"
+
+		result, err := h.PurgeSession("session_abc123")
+		assert.ErrorIs(t, err, errRecorded)
+		assert.ErrorContains(t, err, "derived_metrics")
+		assert.NotContains(t, err.Error(), "otel_traces")
+		assert.Equal(t, map[string]int64{"otel_traces": 0, "derived_metrics": 0, "span_metrics": 0}, result.Deleted)
+		assert.Equal(t, []string{"derived_metrics"}, result.Failed)
+
+		require.Len(t, pool.statements, 6)
+		assert.Contains(t, pool.statements[3], "ALTER TABLE `span_metrics` DELETE WHERE SessionId = ?")
+		assert.Contains(t, pool.statements[5], "ALTER TABLE `otel_traces` DELETE WHERE SpanAttributes['session.id'] = ?")
+	})
+
+	t.Run("Traces should be matched on the extracted session id", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.SessionID, _ = NewSessionIDStrategy("split", "")
+
+		_, err := h.PurgeSession("78e610a0")
+		assert.NoError(t, err)
+		assert.Contains(t, pool.statements[3], "ALTER TABLE `otel_traces` DELETE WHERE splitByChar('_', SpanAttributes['session.id'])[2] = ?")
+		assert.NotContains(t, pool.statements[3], "LIKE")
+	})
+}
+
+func TestGetCallGraphEdges(t *testing.T) {
+	t.Run("Transitions should be counted over the time-ordered spans of the session", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, err := h.GetCallGraphEdges("session_abc123")
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		statement := pool.statements[0]
+		assert.Contains(t, statement, "FROM (SELECT arrayMap(x -> x.2, arraySort(x -> x.1, groupArray((Timestamp, SpanName)))) AS spans FROM `otel_traces` WHERE SpanAttributes['session.id'] LIKE ?) AS span_list")
+		assert.Contains(t, statement, "arrayPushFront(spans, 'START') AS from_span")
+		assert.Contains(t, statement, "arrayPushBack(spans, 'END') AS to_span")
+		assert.Contains(t, statement, "WHERE notEmpty(spans)")
+		assert.Contains(t, statement, "GROUP BY from_span, to_span")
+		assert.Equal(t, []interface{}{"%session_abc123"}, pool.args[0])
+	})
+
+	t.Run("Spans should be selected with the session id strategy", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.SessionID, _ = NewSessionIDStrategy("split", "")
+
+		_, err := h.GetCallGraphEdges("78e610a0")
+		assert.ErrorIs(t, err, errRecorded)
+		assert.Contains(t, pool.statements[0], "WHERE splitByChar('_', SpanAttributes['session.id'])[2] = ?")
+	})
+}
+
+func TestGetSessionIDSUniqueWithPagination(t *testing.T) {
+	start := time.Date(2023, 6, 25, 15, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 6, 25, 18, 0, 0, 0, time.UTC)
+
+	t.Run("Without cursor the page should be read by offset with one extra row", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, _, _, err := h.GetSessionIDSUniqueWithPagination(start, end, 2, 10, nil, "")
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "ORDER BY StartTimestamp DESC, ID DESC LIMIT ? OFFSET ?")
+		assert.NotContains(t, pool.statements[0], "HAVING")
+		assert.Equal(t, []interface{}{start, end, 11, 20}, pool.args[0])
+	})
+
+	t.Run("With cursor the page should start after the last session seen", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		cursor := encodeSessionCursor(models.SessionUniqueID{ID: "session_abc123", StartTimestamp: "2023-06-25T15:30:00.123456789Z"})
+
+		_, _, _, err := h.GetSessionIDSUniqueWithPagination(start, end, 2, 10, nil, cursor)
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "HAVING (MIN(Timestamp), SpanAttributes['session.id']) < (parseDateTime64BestEffort(?, 9), ?)")
+		assert.NotContains(t, pool.statements[0], "OFFSET")
+		assert.Equal(t, []interface{}{start, end, "2023-06-25T15:30:00.123456789Z", "session_abc123", 11}, pool.args[0])
+	})
+
+	t.Run("Invalid cursor should be rejected without querying", func(t *testing.T) {
+		invalid := []string{
+			"not base64!",
+			base64.RawURLEncoding.EncodeToString([]byte("not json")),
+			encodeSessionCursor(models.SessionUniqueID{ID: "", StartTimestamp: "2023-06-25T15:30:00Z"}),
+			encodeSessionCursor(models.SessionUniqueID{ID: "session_abc123", StartTimestamp: "yesterday"}),
+		}
+		for _, cursor := range invalid {
+			h, pool := newTestHandler(t)
+
+			_, _, _, err := h.GetSessionIDSUniqueWithPagination(start, end, 0, 10, nil, cursor)
+			assert.ErrorIs(t, err, models.ErrInvalidCursor, cursor)
+			assert.Empty(t, pool.statements)
+		}
+	})
+
+	t.Run("Stepping through pages by cursor should not skip or repeat sessions", func(t *testing.T) {
+		// Sessions newest first, with ties on the start timestamp broken by ID
+		all := []models.SessionUniqueID{
+			{ID: "session_f", StartTimestamp: "2023-06-25T17:00:00Z"},
+			{ID: "session_e", StartTimestamp: "2023-06-25T16:00:00Z"},
+			{ID: "session_d", StartTimestamp: "2023-06-25T16:00:00Z"},
+			{ID: "session_c", StartTimestamp: "2023-06-25T16:00:00Z"},
+			{ID: "session_b", StartTimestamp: "2023-06-25T15:00:00Z"},
+			{ID: "session_a", StartTimestamp: "2023-06-25T15:00:00Z"},
+			{ID: "session_0", StartTimestamp: "2023-06-25T14:00:00Z"},
+		}
+		conn := &sessionPagesConn{t: t, sessions: all}
+		db, err := gorm.Open(clickhouse.New(clickhouse.Config{
+			Conn:                      sql.OpenDB(conn),
+			SkipInitializeWithVersion: true,
+		}), &gorm.Config{Logger: logger.Discard})
+		require.NoError(t, err)
+		h := New(db)
+
+		var seen []models.SessionUniqueID
+		page, total, cursor, err := h.GetSessionIDSUniqueWithPagination(start, end, 0, 2, nil, "")
+		require.NoError(t, err)
+		assert.Equal(t, len(all), total)
+		seen = append(seen, page...)
+		for pages := 0; cursor != "" && pages < len(all); pages++ {
+			page, total, cursor, err = h.GetSessionIDSUniqueWithPagination(start, end, 0, 2, nil, cursor)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, len(page), 2)
+			assert.Zero(t, total)
+			seen = append(seen, page...)
+		}
+		assert.Equal(t, all, seen)
+		assert.Empty(t, cursor)
+
+		// One page and one count without cursor, then only keyset pages
+		require.Len(t, conn.statements, 5)
+		assert.Contains(t, conn.statements[1], "count(*)")
+		for _, statement := range conn.statements[2:] {
+			assert.Contains(t, statement, "HAVING (MIN(Timestamp), SpanAttributes['session.id']) < (parseDateTime64BestEffort(?, 9), ?)")
+			assert.Contains(t, statement, "ORDER BY StartTimestamp DESC, ID DESC LIMIT ?")
+			assert.NotContains(t, statement, "count(*)")
+		}
+	})
+
+	t.Run("Last page should not return a cursor", func(t *testing.T) {
+		sessions := []models.SessionUniqueID{{ID: "session_abc123", StartTimestamp: "2023-06-25T15:30:00Z"}}
+
+		page, cursor := nextSessionCursor(sessions, 1)
+		assert.Equal(t, sessions, page)
+		assert.Empty(t, cursor)
+	})
+}
+
+func TestGetSessionEvents(t *testing.T) {
+	t.Run("Only spans of the session with events should be read", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, err := h.GetSessionEvents("session_abc123", nil)
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "SELECT TraceId, SpanId, SpanName, Events.Timestamp, Events.Name, Events.Attributes FROM `otel_traces`")
+		assert.Contains(t, pool.statements[0], "WHERE notEmpty(Events.Name) AND SpanAttributes['session.id'] LIKE ?")
+		assert.Equal(t, []interface{}{"%session_abc123"}, pool.args[0])
+	})
+
+	t.Run("Event name should skip spans without that event", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		eventName := "tool_call"
+
+		_, err := h.GetSessionEvents("session_abc123", &eventName)
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "has(Events.Name, ?)")
+		assert.Equal(t, []interface{}{"tool_call", "%session_abc123"}, pool.args[0])
+	})
+
+	t.Run("Multi-event spans should be unrolled in timestamp order", func(t *testing.T) {
+		at := func(minute int) time.Time { return time.Date(2023, 6, 25, 15, minute, 0, 0, time.UTC) }
+		traces := []models.OtelTraces{
+			{
+				TraceId: "trace_1", SpanId: "span_1", SpanName: "agent",
+				EventsTimestamp:  []time.Time{at(1), at(4)},
+				EventsName:       []string{"tool_call", "tool_result"},
+				EventsAttributes: []map[string]string{{"tool.name": "search"}, {"tool.status": "ok"}},
+			},
+			{
+				TraceId: "trace_1", SpanId: "span_2", SpanName: "llm",
+				EventsTimestamp:  []time.Time{at(2), at(3), at(5)},
+				EventsName:       []string{"retry", "tool_call", "retry"},
+				EventsAttributes: []map[string]string{{}, {"tool.name": "calculator"}, {}},
+			},
+		}
+
+		events := sessionEvents(traces, nil)
+		require.Len(t, events, 5)
+		names := make([]string, len(events))
+		for i, event := range events {
+			names[i] = event.SpanId + "/" + event.Name
+		}
+		assert.Equal(t, []string{"span_1/tool_call", "span_2/retry", "span_2/tool_call", "span_1/tool_result", "span_2/retry"}, names)
+		assert.Equal(t, models.SpanEvent{TraceId: "trace_1", SpanId: "span_2", SpanName: "llm", Timestamp: at(3), Name: "tool_call", Attributes: map[string]string{"tool.name": "calculator"}}, events[2])
+
+		eventName := "tool_call"
+		filtered := sessionEvents(traces, &eventName)
+		require.Len(t, filtered, 2)
+		assert.Equal(t, "search", filtered[0].Attributes["tool.name"])
+		assert.Equal(t, "calculator", filtered[1].Attributes["tool.name"])
+	})
+
+	t.Run("No events should return an empty list", func(t *testing.T) {
+		assert.Equal(t, []models.SpanEvent{}, sessionEvents(nil, nil))
+	})
+}
+
+func TestGetTraceStats(t *testing.T) {
+	t.Run("Only the spans of the trace should be read", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, err := h.GetTraceStats("trace_abc123")
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "SELECT Timestamp, ServiceName, Duration, StatusCode FROM `otel_traces` WHERE TraceId = ?")
+		assert.Equal(t, []interface{}{"trace_abc123"}, pool.args[0])
+	})
+
+	t.Run("Multi-span trace should be aggregated", func(t *testing.T) {
+		start := time.Date(2023, 6, 25, 15, 0, 0, 0, time.UTC)
+		spans := []models.OtelTraces{
+			{Timestamp: start.Add(200 * time.Millisecond), ServiceName: "ml-service", Duration: uint64(300 * time.Millisecond), StatusCode: "Ok"},
+			{Timestamp: start, ServiceName: "api-gateway", Duration: uint64(time.Second), StatusCode: "Unset"},
+			{Timestamp: start.Add(900 * time.Millisecond), ServiceName: "ml-service", Duration: uint64(600 * time.Millisecond), StatusCode: "Error"},
+			{Timestamp: start.Add(400 * time.Millisecond), ServiceName: "vector-db", Duration: uint64(50 * time.Millisecond), StatusCode: "STATUS_CODE_ERROR"},
+		}
+
+		assert.Equal(t, models.TraceStats{
+			TraceId:         "trace_abc123",
+			SpanCount:       4,
+			ErrorSpanCount:  2,
+			Services:        []string{"api-gateway", "ml-service", "vector-db"},
+			TotalDurationMs: 1500,
+		}, traceStats("trace_abc123", spans))
+	})
+}
+
+func TestGetTokenUsagePerSession(t *testing.T) {
+	start := time.Date(2023, 6, 25, 15, 0, 0, 0, time.UTC)
+	end := time.Date(2023, 6, 25, 18, 0, 0, 0, time.UTC)
+
+	t.Run("Tokens should be summed per session in the window", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		_, _, err := h.GetTokenUsagePerSession(start, end, 2, 10)
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		statement := pool.statements[0]
+		assert.Contains(t, statement, "SUM(toInt64OrZero(SpanAttributes['llm.usage.total_tokens'])) AS TotalTokens")
+		assert.Contains(t, statement, "WHERE SpanAttributes['session.id'] != '' AND SpanAttributes['llm.usage.total_tokens'] != '' AND (Timestamp >= ? AND Timestamp <= ?)")
+		assert.Contains(t, statement, "GROUP BY SpanAttributes['session.id'] ORDER BY TotalTokens DESC, SessionID ASC LIMIT ? OFFSET ?")
+		assert.Equal(t, []interface{}{start, end, 10, 20}, pool.args[0])
+	})
+
+	t.Run("Sessions should be grouped with the session id strategy", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.SessionID, _ = NewSessionIDStrategy("split", "")
+
+		_, _, _ = h.GetTokenUsagePerSession(start, end, 0, 10)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "splitByChar('_', SpanAttributes['session.id'])[2] AS SessionID")
+		assert.Contains(t, pool.statements[0], "GROUP BY splitByChar('_', SpanAttributes['session.id'])[2]")
+	})
+}
+
+func TestGetGraphDeterminism(t *testing.T) {
+	t.Run("Session paths of the app should be read in the window", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		start := time.Date(2023, 6, 25, 15, 0, 0, 0, time.UTC)
+		end := time.Date(2023, 6, 25, 18, 0, 0, 0, time.UTC)
+
+		_, err := h.GetGraphDeterminism("ml-service", start, end)
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "arrayMap(x -> x.2, arraySort(x -> x.1, groupArray((Timestamp, SpanName)))) AS Path")
+		assert.Contains(t, pool.statements[0], "AND ServiceName = ? AND (Timestamp >= ? AND Timestamp <= ?)")
+		assert.Contains(t, pool.statements[0], "GROUP BY SpanAttributes['session.id']")
+		assert.Equal(t, []interface{}{"ml-service", start, end}, pool.args[0])
+	})
+
+	t.Run("Identical paths should be fully deterministic", func(t *testing.T) {
+		path := []string{"agent", "llm_call", "tool_call"}
+		determinism := graphDeterminism([]sessionPath{
+			{SessionID: "session_1", Path: path},
+			{SessionID: "session_2", Path: path},
+			{SessionID: "session_3", Path: path},
+		})
+
+		assert.Equal(t, models.GraphDeterminism{
+			SessionCount:      3,
+			UniquePathCount:   1,
+			DominantPath:      path,
+			DominantPathCount: 3,
+			Score:             1,
+		}, determinism)
+	})
+
+	t.Run("Divergent paths should score the share of the dominant path", func(t *testing.T) {
+		determinism := graphDeterminism([]sessionPath{
+			{SessionID: "session_1", Path: []string{"agent", "tool_call"}},
+			{SessionID: "session_2", Path: []string{"agent", "llm_call", "tool_call"}},
+			{SessionID: "session_3", Path: []string{"agent", "llm_call", "tool_call"}},
+			{SessionID: "session_4", Path: []string{"agent", "llm_call"}},
+		})
+
+		assert.Equal(t, 4, determinism.SessionCount)
+		assert.Equal(t, 3, determinism.UniquePathCount)
+		assert.Equal(t, []string{"agent", "llm_call", "tool_call"}, determinism.DominantPath)
+		assert.Equal(t, 2, determinism.DominantPathCount)
+		assert.Equal(t, 0.5, determinism.Score)
+	})
+
+	t.Run("Ties should not depend on the session order", func(t *testing.T) {
+		a := sessionPath{SessionID: "session_1", Path: []string{"agent", "tool_call"}}
+		b := sessionPath{SessionID: "session_2", Path: []string{"agent", "llm_call"}}
+
+		assert.Equal(t, []string{"agent", "llm_call"}, graphDeterminism([]sessionPath{a, b}).DominantPath)
+		assert.Equal(t, []string{"agent", "llm_call"}, graphDeterminism([]sessionPath{b, a}).DominantPath)
+	})
+
+	t.Run("No sessions should score zero", func(t *testing.T) {
+		assert.Equal(t, models.GraphDeterminism{DominantPath: []string{}}, graphDeterminism(nil))
+	})
+}
+
+func TestGetSpansWithMetrics(t *testing.T) {
+	t.Run("Metrics of the session should be counted per span", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = map[string]string{"span": "span_metrics"}
+
+		_, err := h.GetSpansWithMetrics("session_abc123", "span")
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		assert.Contains(t, pool.statements[0], "SELECT SpanId, count() AS MetricCount FROM `span_metrics`")
+		assert.Contains(t, pool.statements[0], "WHERE SessionId = ? AND Scope = ? AND SpanId != '' GROUP BY `SpanId` ORDER BY SpanId ASC")
+		assert.Equal(t, []interface{}{"session_abc123", "span"}, pool.args[0])
+	})
+}
+
+func TestGetMetricMatrix(t *testing.T) {
+	t.Run("Every key should be averaged per app", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+		h.MetricTables = map[string]string{"span": "span_metrics"}
+
+		_, err := h.GetMetricMatrix([]string{"accuracy", "latency_ms"}, "span")
+		assert.ErrorIs(t, err, errRecorded)
+		require.Len(t, pool.statements, 1)
+		statement := pool.statements[0]
+		assert.Contains(t, statement, "SELECT AppName, avgOrNull(toFloat64OrNull(trim(BOTH '\"' FROM JSONExtractRaw(Metrics, ?)))) AS Value0, avgOrNull(toFloat64OrNull(trim(BOTH '\"' FROM JSONExtractRaw(Metrics, ?)))) AS Value1 FROM `span_metrics`")
+		assert.Contains(t, statement, "WHERE Scope = ? GROUP BY `AppName` ORDER BY AppName ASC")
+		assert.Equal(t, []interface{}{"accuracy", "latency_ms", "span"}, pool.args[0])
+	})
+
+	t.Run("No keys should return an empty matrix without querying", func(t *testing.T) {
+		h, pool := newTestHandler(t)
+
+		matrix, err := h.GetMetricMatrix(nil, "session")
+		assert.NoError(t, err)
+		assert.Empty(t, matrix.Apps)
+		assert.Empty(t, pool.statements)
+	})
+}
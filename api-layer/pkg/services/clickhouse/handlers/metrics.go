@@ -4,30 +4,304 @@
 package handlers
 
 import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"gorm.io/gorm"
+
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/common"
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/models"
 )
 
 func (h Handler) AddMetric(metric models.Metric) (models.Metric, error) {
-	if result := h.DB.Create(&metric); result.Error != nil {
+	query := h.DB
+	if metric.Scope != nil {
+		query = query.Table(h.metricTable(*metric.Scope))
+	}
+	if result := query.Create(&metric); result.Error != nil {
 		logger.Zap.Error("Error", logger.Error(result.Error))
 		return metric, result.Error
 	}
 	return metric, nil
 }
 
-func (h Handler) GetMetricsBySessionIdAndScope(sessionId string, scope string) (metrics []models.Metric, err error) {
-	if result := h.DB.Where("SessionId = ?", sessionId).Where("Scope = ?", scope).Find(&metrics); result.Error != nil {
+// UpsertMetric replaces, for the same session, span and scope, the stored
+// values of every metric key of the given metric, then inserts it. Stored rows
+// keep their other keys, and rows left without any key are deleted. Session
+// metrics without a span are keyed on the empty span
+func (h Handler) UpsertMetric(metric models.Metric) (models.Metric, error) {
+	if metric.Metrics == nil || metric.SessionId == nil || *metric.SessionId == "" ||
+		metric.Scope == nil || *metric.Scope == "" {
+		return metric, models.ErrIncompleteUpsert
+	}
+	if metric.SpanId == nil || *metric.SpanId == "" {
+		if *metric.Scope != common.METRIC_SCOPE_SESSION {
+			return metric, models.ErrIncompleteUpsert
+		}
+		noSpan := ""
+		metric.SpanId = &noSpan
+	}
+
+	keys, err := metric.Metrics.Keys()
+	if err != nil {
+		logger.Zap.Error("Error", logger.Error(err))
+		return metric, err
+	}
+
+	if len(keys) > 0 {
+		table := h.metricTable(*metric.Scope)
+		var stored []models.Metric
+		result := h.DB.
+			Table(table).
+			Select("ID, Metrics").
+			Where("SessionId = ?", *metric.SessionId).
+			Where("SpanId = ?", *metric.SpanId).
+			Where("Scope = ?", *metric.Scope).
+			Where("arrayExists(k -> k IN ?, JSONExtractKeys(Metrics))", keys).
+			Find(&stored)
+		if result.Error != nil {
+			logger.Zap.Error("Error", logger.Error(result.Error))
+			return metric, result.Error
+		}
+
+		rewrites, deletes, err := upsertPlan(stored, keys)
+		if err != nil {
+			logger.Zap.Error("Error", logger.Error(err))
+			return metric, err
+		}
+		if err := h.applyUpsertPlan(table, rewrites, deletes); err != nil {
+			return metric, err
+		}
+	}
+
+	return h.AddMetric(metric)
+}
+
+// upsertPlan removes the given keys from the stored metrics. Rows keeping other
+// keys are returned as rewrites with their remaining metrics, the ids of rows
+// left empty are returned as deletes
+func upsertPlan(stored []models.Metric, keys []string) (rewrites []models.Metric, deletes []string, err error) {
+	for _, row := range stored {
+		if row.ID == nil || row.Metrics == nil {
+			continue
+		}
+		remaining, err := row.Metrics.WithoutKeys(keys)
+		if err != nil {
+			return nil, nil, err
+		}
+		if left, err := remaining.Keys(); err != nil || len(left) > 0 {
+			rewrites = append(rewrites, models.Metric{ID: row.ID, Metrics: &remaining})
+			continue
+		}
+		deletes = append(deletes, *row.ID)
+	}
+	return rewrites, deletes, nil
+}
+
+// applyUpsertPlan runs the rewrites and deletes of an upsert as synchronous
+// mutations, so readers never see a replaced value next to its replacement
+func (h Handler) applyUpsertPlan(table string, rewrites []models.Metric, deletes []string) error {
+	db := h.DB.WithContext(clickhouse.Context(context.Background(), clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 1,
+	})))
+
+	for _, row := range rewrites {
+		if result := db.Table(table).Where("ID = ?", *row.ID).Update("Metrics", *row.Metrics); result.Error != nil {
+			logger.Zap.Error("Error", logger.Error(result.Error))
+			return result.Error
+		}
+	}
+	if len(deletes) > 0 {
+		if result := db.Table(table).Where("ID IN ?", deletes).Delete(&models.Metric{}); result.Error != nil {
+			logger.Zap.Error("Error", logger.Error(result.Error))
+			return result.Error
+		}
+	}
+	return nil
+}
+
+// paginateMetrics orders the metrics by timestamp and, when limit is positive,
+// returns only the given zero-based page
+func paginateMetrics(query *gorm.DB, page, limit int, sort string) *gorm.DB {
+	if sort == common.SORT_TIMESTAMP_ASC {
+		query = query.Order("Timestamp ASC")
+	} else {
+		query = query.Order("Timestamp DESC")
+	}
+	if limit > 0 {
+		query = query.Offset(page * limit).Limit(limit)
+	}
+	return query
+}
+
+func (h Handler) GetMetricsBySessionIdAndScope(sessionId string, scope string, page, limit int, sort string) (metrics []models.Metric, err error) {
+	query := h.DB.Table(h.metricTable(scope)).Where("SessionId = ?", sessionId).Where("Scope = ?", scope)
+	if result := paginateMetrics(query, page, limit, sort).Find(&metrics); result.Error != nil {
 		logger.Zap.Error("Error", logger.Error(result.Error))
 		return nil, result.Error
 	}
 	return metrics, nil
 }
 
-func (h Handler) GetMetricsBySpanIdAndScope(spanId string, scope string) (metrics []models.Metric, err error) {
-	if result := h.DB.Where("SpanId = ?", spanId).Where("Scope = ?", scope).Find(&metrics); result.Error != nil {
+func (h Handler) GetMetricsBySpanIdAndScope(spanId string, scope string, page, limit int, sort string) (metrics []models.Metric, err error) {
+	query := h.DB.Table(h.metricTable(scope)).Where("SpanId = ?", spanId).Where("Scope = ?", scope)
+	if result := paginateMetrics(query, page, limit, sort).Find(&metrics); result.Error != nil {
 		logger.Zap.Error("Error", logger.Error(result.Error))
 		return nil, result.Error
 	}
 	return metrics, nil
 }
+
+// GetSpansWithMetrics returns the spans of the session that have at least
+// one metric of the given scope, with their metric count, sorted by span id
+func (h Handler) GetSpansWithMetrics(sessionID string, scope string) ([]models.SpanMetricCount, error) {
+	spans := []models.SpanMetricCount{}
+	result := h.DB.Table(h.metricTable(scope)).
+		Select("SpanId, count() AS MetricCount").
+		Where("SessionId = ?", sessionID).
+		Where("Scope = ?", scope).
+		Where("SpanId != ''").
+		Group("SpanId").
+		Order("SpanId ASC").
+		Find(&spans)
+	if result.Error != nil {
+		logger.Zap.Error("Error", logger.Error(result.Error))
+		return nil, result.Error
+	}
+	return spans, nil
+}
+
+// GetDistinctMetricApps returns the distinct app name and app id pairs found in
+// every metric table, optionally restricted to metrics written in a time range
+func (h Handler) GetDistinctMetricApps(startTime, endTime *time.Time) ([]models.AppInfo, error) {
+	seen := map[models.AppInfo]bool{}
+	apps := []models.AppInfo{}
+	for _, table := range h.metricTableNames() {
+		query := h.DB.Table(table).Select("DISTINCT AppName, AppId")
+		if startTime != nil {
+			query = query.Where("Timestamp >= ?", *startTime)
+		}
+		if endTime != nil {
+			query = query.Where("Timestamp <= ?", *endTime)
+		}
+
+		var tableApps []models.AppInfo
+		if result := query.Find(&tableApps); result.Error != nil {
+			logger.Zap.Error("Error", logger.Error(result.Error))
+			return nil, result.Error
+		}
+		for _, app := range tableApps {
+			if !seen[app] {
+				seen[app] = true
+				apps = append(apps, app)
+			}
+		}
+	}
+
+	sort.Slice(apps, func(i, j int) bool {
+		if apps[i].AppName != apps[j].AppName {
+			return apps[i].AppName < apps[j].AppName
+		}
+		return apps[i].AppId < apps[j].AppId
+	})
+	return apps, nil
+}
+
+// metricValue reads the metric key bound to its placeholder as a Float64,
+// accepting numbers and numeric strings and returning NULL otherwise
+const metricValue = "toFloat64OrNull(trim(BOTH '\"' FROM JSONExtractRaw(Metrics, ?)))"
+
+// rollupFunctions maps a roll-up aggregation to its ClickHouse function,
+// returning NULL instead of a default value when there is nothing to aggregate
+var rollupFunctions = map[string]string{
+	common.METRIC_ROLLUP_SUM: "sumOrNull",
+	common.METRIC_ROLLUP_AVG: "avgOrNull",
+	common.METRIC_ROLLUP_MIN: "minOrNull",
+	common.METRIC_ROLLUP_MAX: "maxOrNull",
+}
+
+// RollupSpanMetricsToSession aggregates one key of the span metrics of a
+// session. Values stored as numbers or numeric strings are used, others are
+// ignored
+func (h Handler) RollupSpanMetricsToSession(sessionID, metricKey, agg string) (float64, error) {
+	function, ok := rollupFunctions[agg]
+	if !ok {
+		return 0, fmt.Errorf("invalid metric roll-up aggregation %q", agg)
+	}
+
+	var result struct {
+		Value *float64
+	}
+	query := h.DB.
+		Table(h.metricTable(common.METRIC_SCOPE_SPAN)).
+		Select(function+"("+metricValue+") AS Value", metricKey).
+		Where("SessionId = ?", sessionID).
+		Where("Scope = ?", common.METRIC_SCOPE_SPAN)
+	if res := query.Scan(&result); res.Error != nil {
+		logger.Zap.Error("Error", logger.Error(res.Error))
+		return 0, res.Error
+	}
+	if result.Value == nil {
+		return 0, models.ErrNoMetricValues
+	}
+	return *result.Value, nil
+}
+
+// GetMetricMatrix averages each metric key per app over the metrics of the
+// given scope. An app without any numeric value for a key gets a nil average
+func (h Handler) GetMetricMatrix(keys []string, scope string) (models.MetricMatrix, error) {
+	matrix := models.MetricMatrix{Keys: keys, Apps: []models.MetricMatrixRow{}}
+	if len(keys) == 0 {
+		return matrix, nil
+	}
+
+	columns := []string{"AppName"}
+	args := make([]interface{}, 0, len(keys))
+	for i, key := range keys {
+		columns = append(columns, fmt.Sprintf("avgOrNull(%s) AS Value%d", metricValue, i))
+		args = append(args, key)
+	}
+
+	rows, err := h.DB.
+		Table(h.metricTable(scope)).
+		Select(strings.Join(columns, ", "), args...).
+		Where("Scope = ?", scope).
+		Group("AppName").
+		Order("AppName ASC").
+		Rows()
+	if err != nil {
+		logger.Zap.Error("Error", logger.Error(err))
+		return matrix, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var appName string
+		values := make([]sql.NullFloat64, len(keys))
+		dest := []interface{}{&appName}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			logger.Zap.Error("Error scanning metric matrix", logger.Error(err))
+			return matrix, err
+		}
+
+		row := models.MetricMatrixRow{AppName: appName, Values: make(map[string]*float64, len(keys))}
+		for i, key := range keys {
+			row.Values[key] = nil
+			if values[i].Valid {
+				value := values[i].Float64
+				row.Values[key] = &value
+			}
+		}
+		matrix.Apps = append(matrix.Apps, row)
+	}
+	return matrix, rows.Err()
+}
@@ -4,6 +4,9 @@
 package handlers
 
 import (
+	"strings"
+	"time"
+
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/models"
 )
@@ -47,6 +50,92 @@ func (h Handler) GetTokenUsageCountPerAgent() ([]models.AgentsTokenUsage, error)
 	return results, nil
 }
 
+// GetTokenUsagePerSession sums llm.usage.total_tokens per session over the
+// spans of the time window, largest first, with the number of sessions that
+// used tokens
+func (h Handler) GetTokenUsagePerSession(startTime, endTime time.Time, page, limit int) ([]models.SessionTokenUsage, int, error) {
+	baseQuery := h.DB.Table("otel_traces").
+		Select(h.SessionID.Column()+` AS SessionID,
+		SUM(toInt64OrZero(SpanAttributes['llm.usage.total_tokens'])) AS TotalTokens`).
+		Where(h.SessionID.Column()+" != ''").
+		Where("SpanAttributes['llm.usage.total_tokens'] != ''").
+		Where("Timestamp >= ? AND Timestamp <= ?", startTime, endTime).
+		Group(h.SessionID.Column())
+
+	var results []models.SessionTokenUsage
+	res := baseQuery.
+		Order("TotalTokens DESC, SessionID ASC").
+		Offset(page * limit).
+		Limit(limit).
+		Find(&results)
+	if res.Error != nil {
+		logger.Zap.Error("Error", logger.Error(res.Error))
+		return nil, 0, res.Error
+	}
+
+	var total int64
+	if err := h.DB.Table("(?) as sub", baseQuery).Count(&total).Error; err != nil {
+		logger.Zap.Error("Error", logger.Error(err))
+		return nil, 0, err
+	}
+	return results, int(total), nil
+}
+
+// sessionPath is the execution path of a session, its span names ordered by timestamp
+type sessionPath struct {
+	SessionID string
+	Path      []string
+}
+
+// GetGraphDeterminism reads the execution path of every session of the app in
+// the time window and measures how many of them follow the dominant path
+func (h Handler) GetGraphDeterminism(appName string, startTime, endTime time.Time) (models.GraphDeterminism, error) {
+	var paths []sessionPath
+	err := h.DB.Table("otel_traces").
+		Select(h.SessionID.Column()+` AS SessionID,
+		arrayMap(x -> x.2, arraySort(x -> x.1, groupArray((Timestamp, SpanName)))) AS Path`).
+		Where(h.SessionID.Column()+" != ''").
+		Where("ServiceName = ?", appName).
+		Where("Timestamp >= ? AND Timestamp <= ?", startTime, endTime).
+		Group(h.SessionID.Column()).
+		Scan(&paths).Error
+	if err != nil {
+		logger.Zap.Error("Error", logger.Error(err))
+		return models.GraphDeterminism{}, err
+	}
+
+	determinism := graphDeterminism(paths)
+	determinism.AppName = appName
+	return determinism, nil
+}
+
+// graphDeterminism groups identical session paths and keeps the most frequent
+// one. Equally frequent paths are broken by their span names so the result
+// does not depend on the row order
+func graphDeterminism(paths []sessionPath) models.GraphDeterminism {
+	determinism := models.GraphDeterminism{DominantPath: []string{}}
+
+	counts := make(map[string]int)
+	dominantKey := ""
+	for _, path := range paths {
+		key := strings.Join(path.Path, "\x00")
+		counts[key]++
+		count := counts[key]
+		if count > determinism.DominantPathCount || (count == determinism.DominantPathCount && key < dominantKey) {
+			dominantKey = key
+			determinism.DominantPath = path.Path
+			determinism.DominantPathCount = count
+		}
+	}
+
+	determinism.SessionCount = len(paths)
+	determinism.UniquePathCount = len(counts)
+	if determinism.SessionCount > 0 {
+		determinism.Score = float64(determinism.DominantPathCount) / float64(determinism.SessionCount)
+	}
+	return determinism
+}
+
 func (h Handler) GetResponseLatencyStatsPerAgent() ([]models.ResponseLatencyPerAgent, error) {
 
 	// Query most frequently used agents
@@ -69,6 +158,35 @@ func (h Handler) GetResponseLatencyStatsPerAgent() ([]models.ResponseLatencyPerA
 	return results, nil
 }
 
+// GetSpanDurationStats returns count, average, max and p95 duration in milliseconds
+// grouped by span name, optionally restricted to a service
+func (h Handler) GetSpanDurationStats(serviceName *string, startTime, endTime time.Time) ([]models.SpanDurationStat, error) {
+
+	// Duration is stored in nanoseconds
+	var results []models.SpanDurationStat
+	query := h.DB.Table("otel_traces").
+		Select(`SpanName,
+		COUNT(*) AS Count,
+		AVG(Duration)/1000000 AS AvgDurationMs,
+		MAX(Duration)/1000000 AS MaxDurationMs,
+		quantile(0.95)(Duration)/1000000 AS P95DurationMs`).
+		Where("Timestamp >= ? AND Timestamp <= ?", startTime, endTime)
+
+	if serviceName != nil && *serviceName != "" {
+		query = query.Where("ServiceName = ?", *serviceName)
+	}
+
+	res := query.
+		Group("SpanName").
+		Order("AvgDurationMs DESC").
+		Find(&results)
+	if res.Error != nil {
+		logger.Zap.Error("Error", logger.Error(res.Error))
+		return nil, res.Error
+	}
+	return results, nil
+}
+
 func (h Handler) GetCallGraph(executionId string) ([]models.CallGraph, error) {
 
 	// Query call graph based on execution ID
@@ -107,6 +225,39 @@ func (h Handler) GetCallGraph(executionId string) ([]models.CallGraph, error) {
 	return results, nil
 }
 
+// GetCallGraphEdges aggregates the transitions between consecutive spans of a
+// session, ordered by timestamp, into directed edges with occurrence counts.
+// As in GetCallGraph the first span is reached from START and the last one
+// leads to END
+func (h Handler) GetCallGraphEdges(sessionID string) ([]models.CallGraphEdge, error) {
+
+	spans := h.SessionID.Where(
+		h.DB.Table("otel_traces").
+			Select("arrayMap(x -> x.2, arraySort(x -> x.1, groupArray((Timestamp, SpanName)))) AS spans"),
+		sessionID,
+	)
+
+	var results []models.CallGraphEdge
+	err := h.DB.Raw(`
+    SELECT
+        from_span AS FromSpan,
+        to_span AS ToSpan,
+        count() AS Count
+    FROM (?) AS span_list
+    ARRAY JOIN
+        arrayPushFront(spans, 'START') AS from_span,
+        arrayPushBack(spans, 'END') AS to_span
+    WHERE notEmpty(spans)
+    GROUP BY from_span, to_span
+    ORDER BY Count DESC, FromSpan ASC, ToSpan ASC
+`, spans).Scan(&results).Error
+	if err != nil {
+		logger.Zap.Error("Error", logger.Error(err))
+		return nil, err
+	}
+	return results, nil
+}
+
 func (h Handler) GetAGPMetrics(executionId string) ([]models.AGPMetrics, error) {
 
 	// Query call graph based on execution ID
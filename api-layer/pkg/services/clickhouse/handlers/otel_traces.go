@@ -4,7 +4,11 @@
 package handlers
 
 import (
+	"sort"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/models"
@@ -23,13 +27,36 @@ func (h Handler) GetTraces() ([]models.OtelTraces, error) {
 func (h Handler) GetTracesBySessionID(sessionID string) ([]models.OtelTraces, error) {
 	var traces []models.OtelTraces
 
-	if result := h.DB.Where("SpanAttributes['session.id'] LIKE ?", "%"+sessionID).Find(&traces); result.Error != nil {
+	if result := h.SessionID.Where(h.DB, sessionID).Find(&traces); result.Error != nil {
 		logger.Zap.Error("Error", logger.Error(result.Error))
 		return traces, result.Error
 	}
 	return traces, nil
 }
 
+// StreamTracesBySessionID calls fn for each span of the session as rows are read,
+// without loading the whole result in memory
+func (h Handler) StreamTracesBySessionID(sessionID string, fn func(models.OtelTraces) error) error {
+	rows, err := h.SessionID.Where(h.DB.Model(&models.OtelTraces{}), sessionID).Rows()
+	if err != nil {
+		logger.Zap.Error("Error", logger.Error(err))
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var trace models.OtelTraces
+		if err := h.DB.ScanRows(rows, &trace); err != nil {
+			logger.Zap.Error("Error scanning trace", logger.Error(err))
+			return err
+		}
+		if err := fn(trace); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (h Handler) GetTracesBySessionIDs(sessionIDs []string) (map[string][]models.OtelTraces, []string, error) {
 	result := make(map[string][]models.OtelTraces)
 
@@ -40,17 +67,18 @@ func (h Handler) GetTracesBySessionIDs(sessionIDs []string) (map[string][]models
 	var allTraces []models.OtelTraces
 
 	// Single query to get all traces for all session IDs
-	if err := h.DB.Where("SpanAttributes['session.id'] IN (?)", sessionIDs).Find(&allTraces).Error; err != nil {
+	if err := h.DB.Where(h.SessionID.Column()+" IN (?)", sessionIDs).Find(&allTraces).Error; err != nil {
 		logger.Zap.Error("Error fetching traces for session IDs", logger.Error(err), logger.Strings("sessionIDs", sessionIDs))
 		return result, []string{}, err
 	}
 
 	// Group traces by session ID in memory
 	for _, trace := range allTraces {
-		sessionIDStr, exists := trace.SpanAttributes["session.id"]
+		rawSessionID, exists := trace.SpanAttributes["session.id"]
 		if !exists {
 			continue
 		}
+		sessionIDStr := h.SessionID.Extract(rawSessionID)
 
 		// Try to match against the requested session IDs
 		matched := false
@@ -81,8 +109,7 @@ func (h Handler) GetTracesBySessionIDs(sessionIDs []string) (map[string][]models
 func (h Handler) GetSpanBySessionIDAndSpanID(sessionID string, spanID string) (models.OtelTraces, error) {
 	var span models.OtelTraces
 
-	result := h.DB.
-		Where("SpanAttributes['session.id'] LIKE ?", "%"+sessionID).
+	result := h.SessionID.Where(h.DB, sessionID).
 		Where("SpanId = ?", spanID).
 		First(&span)
 
@@ -92,3 +119,111 @@ func (h Handler) GetSpanBySessionIDAndSpanID(sessionID string, spanID string) (m
 	}
 	return span, nil
 }
+
+// GetTraceStats returns the span count, error span count, distinct services and
+// total duration of a trace, or gorm.ErrRecordNotFound when it has no spans
+func (h Handler) GetTraceStats(traceID string) (models.TraceStats, error) {
+	var spans []models.OtelTraces
+
+	result := h.DB.Model(&models.OtelTraces{}).
+		Select("Timestamp, ServiceName, Duration, StatusCode").
+		Where("TraceId = ?", traceID).
+		Find(&spans)
+	if result.Error != nil {
+		logger.Zap.Error("Error", logger.Error(result.Error))
+		return models.TraceStats{}, result.Error
+	}
+	if len(spans) == 0 {
+		return models.TraceStats{}, gorm.ErrRecordNotFound
+	}
+	return traceStats(traceID, spans), nil
+}
+
+// traceStats aggregates the given spans of a trace. Spans with an error status
+// are counted as error spans and services are sorted by name
+func traceStats(traceID string, spans []models.OtelTraces) models.TraceStats {
+	stats := models.TraceStats{TraceId: traceID, SpanCount: len(spans), Services: []string{}}
+
+	var start, end time.Time
+	seen := make(map[string]bool)
+	for i, span := range spans {
+		if span.StatusCode == "Error" || span.StatusCode == "STATUS_CODE_ERROR" {
+			stats.ErrorSpanCount++
+		}
+		if span.ServiceName != "" && !seen[span.ServiceName] {
+			seen[span.ServiceName] = true
+			stats.Services = append(stats.Services, span.ServiceName)
+		}
+
+		spanEnd := span.Timestamp.Add(time.Duration(span.Duration))
+		if i == 0 || span.Timestamp.Before(start) {
+			start = span.Timestamp
+		}
+		if i == 0 || spanEnd.After(end) {
+			end = spanEnd
+		}
+	}
+
+	sort.Strings(stats.Services)
+	stats.TotalDurationMs = float64(end.Sub(start)) / float64(time.Millisecond)
+	return stats
+}
+
+// GetSessionEvents returns the events of every span of the session as a flat
+// list ordered by event timestamp, optionally keeping only the events named
+// eventName
+func (h Handler) GetSessionEvents(sessionID string, eventName *string) ([]models.SpanEvent, error) {
+	var traces []models.OtelTraces
+
+	query := h.DB.Model(&models.OtelTraces{}).
+		Select("TraceId, SpanId, SpanName, Events.Timestamp, Events.Name, Events.Attributes").
+		Where("notEmpty(Events.Name)")
+	if eventName != nil && *eventName != "" {
+		query = query.Where("has(Events.Name, ?)", *eventName)
+	}
+
+	if result := h.SessionID.Where(query, sessionID).Find(&traces); result.Error != nil {
+		logger.Zap.Error("Error", logger.Error(result.Error))
+		return nil, result.Error
+	}
+	return sessionEvents(traces, eventName), nil
+}
+
+// sessionEvents unrolls the events of the given spans, keeps the ones named
+// eventName when set and orders them by timestamp
+func sessionEvents(traces []models.OtelTraces, eventName *string) []models.SpanEvent {
+	events := []models.SpanEvent{}
+	for _, trace := range traces {
+		for _, event := range trace.Events() {
+			if eventName != nil && *eventName != "" && event.Name != *eventName {
+				continue
+			}
+			events = append(events, event)
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events
+}
+
+// GetTraceAttributeKeys returns the distinct span attribute keys seen in the time window,
+// optionally restricted to a service
+func (h Handler) GetTraceAttributeKeys(serviceName *string, startTime, endTime time.Time, limit int) ([]string, error) {
+	var keys []string
+
+	query := h.DB.
+		Table("otel_traces").
+		Select("DISTINCT arrayJoin(mapKeys(SpanAttributes)) AS key").
+		Where("Timestamp >= ? AND Timestamp <= ?", startTime, endTime)
+
+	if serviceName != nil && *serviceName != "" {
+		query = query.Where("ServiceName = ?", *serviceName)
+	}
+
+	if result := query.Order("key ASC").Limit(limit).Find(&keys); result.Error != nil {
+		logger.Zap.Error("Error fetching trace attribute keys", logger.Error(result.Error))
+		return keys, result.Error
+	}
+	return keys, nil
+}
@@ -0,0 +1,50 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/models"
+)
+
+// PurgeSession deletes the metrics and traces of a session and returns the
+// number of rows targeted per table. Metrics are deleted first and every table
+// is attempted even when one fails, so a retry finishes a partial purge. The
+// failed tables are listed in the result and their errors are joined. Traces
+// are matched exactly on the session id extracted by the session id strategy,
+// never by suffix, so with the raw strategy spans whose session.id only ends
+// with the id are kept although the suffix lookups of the read endpoints still
+// return them. Deletes are ClickHouse mutations, so purged rows can stay
+// visible for a short time
+func (h Handler) PurgeSession(sessionID string) (models.PurgeResult, error) {
+	result := models.PurgeResult{SessionID: sessionID, Deleted: map[string]int64{}}
+
+	var errs []error
+	for _, table := range h.metricTableNames() {
+		count, err := h.deleteRows(table, &models.Metric{}, "SessionId = ?", sessionID)
+		result.Deleted[table] = count
+		if err != nil {
+			result.Failed = append(result.Failed, table)
+			errs = append(errs, fmt.Errorf("%s: %w", table, err))
+		}
+	}
+
+	tracesTable := models.OtelTraces{}.TableName()
+	count, err := h.deleteRows(tracesTable, &models.OtelTraces{}, h.SessionID.Column()+" = ?", sessionID)
+	result.Deleted[tracesTable] = count
+	if err != nil {
+		result.Failed = append(result.Failed, tracesTable)
+		errs = append(errs, fmt.Errorf("%s: %w", tracesTable, err))
+	}
+
+	if len(errs) > 0 {
+		return result, errors.Join(errs...)
+	}
+
+	logger.Zap.Info("Purged session", logger.String("sessionID", sessionID), logger.Any("deleted", result.Deleted))
+	return result, nil
+}
@@ -0,0 +1,38 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/models"
+)
+
+// RetentionCutoff returns the timestamp before which metrics are older than
+// the given number of retention days
+func RetentionCutoff(now time.Time, retentionDays int) time.Time {
+	return now.UTC().AddDate(0, 0, -retentionDays)
+}
+
+// DeleteMetricsBefore deletes the metrics older than cutoff from every metric
+// table and returns how many rows were targeted. Every table is attempted even
+// when one fails, so a broken table does not stop retention on the others, and
+// the errors of the failed tables are joined
+func (h Handler) DeleteMetricsBefore(cutoff time.Time) (int64, error) {
+	var total int64
+	var errs []error
+	for _, table := range h.metricTableNames() {
+		count, err := h.deleteRows(table, &models.Metric{}, "Timestamp < ?", cutoff)
+		total += count
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", table, err))
+			continue
+		}
+		logger.Zap.Info("Deleted expired metrics", logger.String("table", table), logger.Int64("rows", count), logger.String("cutoff", cutoff.Format(time.RFC3339)))
+	}
+	return total, errors.Join(errs...)
+}
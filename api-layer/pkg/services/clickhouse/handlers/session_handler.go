@@ -4,6 +4,11 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/models"
@@ -14,8 +19,8 @@ func (h Handler) GetSessionIDS(startTime, endTime time.Time) ([]models.SessionID
 
 	result := h.DB.
 		Table("otel_traces").
-		Select("SpanAttributes['session.id'] AS ID, SpanName, Timestamp, ScopeName, ServiceName").
-		Where("SpanAttributes['session.id'] != ''").
+		Select(h.SessionID.Column() + " AS ID, SpanName, Timestamp, ScopeName, ServiceName").
+		Where(h.SessionID.Column() + " != ''").
 		Where("Timestamp >= ? AND Timestamp <= ?", startTime, endTime).
 		Order("Timestamp DESC").
 		Find(&traces)
@@ -31,12 +36,11 @@ func (h Handler) GetSessionIDSUnique(startTime, endTime time.Time) ([]models.Ses
 
 	result := h.DB.
 		Table("otel_traces").
-		Select(`
-			SpanAttributes['session.id'] AS ID,
+		Select(h.SessionID.Column() + ` AS ID,
             MIN(Timestamp) AS StartTimestamp
 		`).
-		Where("SpanAttributes['session.id'] != ''").
-		Group("SpanAttributes['session.id']").
+		Where(h.SessionID.Column() + " != ''").
+		Group(h.SessionID.Column()).
 		Having("MIN(Timestamp) >= ? AND MIN(Timestamp) <= ?", startTime, endTime).
 		Order("StartTimestamp DESC").
 		Find(&sessionIDs)
@@ -53,17 +57,16 @@ func (h Handler) GetSessionIDSWithPrompts(startTime, endTime time.Time) ([]model
 
     result := h.DB.
         Table("otel_traces").
-        Select(`
-            SpanAttributes['session.id'] AS ID,
+        Select(h.SessionID.Column() + ` AS ID,
             MIN(Timestamp) AS StartTimestamp,
             argMin(
                 SpanAttributes['gen_ai.prompt.0.content'],
                 Timestamp
             ) AS Prompt
         `).
-        Where("SpanAttributes['session.id'] != ''").
+        Where(h.SessionID.Column() + " != ''").
         Where("SpanAttributes['gen_ai.prompt.0.role'] = 'user'").
-        Group("SpanAttributes['session.id']").
+        Group(h.SessionID.Column()).
         Having("MIN(Timestamp) >= ? AND MIN(Timestamp) <= ?", startTime, endTime).
         Order("StartTimestamp DESC").
         Find(&sessionIDs)
@@ -74,50 +77,255 @@ func (h Handler) GetSessionIDSWithPrompts(startTime, endTime time.Time) ([]model
     return sessionIDs, nil
 }
 
-func (h Handler) GetSessionIDSUniqueWithPagination(startTime, endTime time.Time, page, limit int, nameFilter *string) (sessionIDs []models.SessionUniqueID, total int, err error) {
+// GetSessionConversation reads the gen_ai.prompt.* and gen_ai.completion.*
+// span attributes of a session, like GetSessionIDSWithPrompts does for the
+// first prompt, and returns them as a chronological message list
+func (h Handler) GetSessionConversation(sessionID string) ([]models.ConversationMessage, error) {
+	var spans []models.OtelTraces
+
+	query := h.DB.Model(&models.OtelTraces{}).
+		Select("Timestamp, SpanId, SpanAttributes").
+		Where("arrayExists(k -> startsWith(k, 'gen_ai.prompt.') OR startsWith(k, 'gen_ai.completion.'), mapKeys(SpanAttributes))")
+	result := h.SessionID.Where(query, sessionID).
+		Order("Timestamp ASC").
+		Find(&spans)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return conversation(spans), nil
+}
+
+// conversationMessages returns the indexed messages stored under prefix in the
+// span attributes, such as gen_ai.prompt.0.role and gen_ai.prompt.0.content,
+// ordered by index
+func conversationMessages(span models.OtelTraces, prefix string) []models.ConversationMessage {
+	byIndex := map[int]*models.ConversationMessage{}
+	for key, value := range span.SpanAttributes {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		index, field, found := strings.Cut(strings.TrimPrefix(key, prefix), ".")
+		n, err := strconv.Atoi(index)
+		if !found || err != nil || (field != "role" && field != "content") {
+			continue
+		}
+		if byIndex[n] == nil {
+			byIndex[n] = &models.ConversationMessage{SpanId: span.SpanId, Timestamp: span.Timestamp}
+		}
+		if field == "role" {
+			byIndex[n].Role = value
+		} else {
+			byIndex[n].Content = value
+		}
+	}
+
+	indexes := make([]int, 0, len(byIndex))
+	for n := range byIndex {
+		indexes = append(indexes, n)
+	}
+	sort.Ints(indexes)
+
+	messages := make([]models.ConversationMessage, 0, len(indexes))
+	for _, n := range indexes {
+		messages = append(messages, *byIndex[n])
+	}
+	return messages
+}
+
+// conversation builds the message list of spans ordered by timestamp. Each
+// gen_ai span usually repeats the history in its prompts, so the prompts that
+// match the start of the conversation built so far are not added again
+func conversation(spans []models.OtelTraces) []models.ConversationMessage {
+	messages := []models.ConversationMessage{}
+	for _, span := range spans {
+		prompts := conversationMessages(span, "gen_ai.prompt.")
+		known := 0
+		for known < len(prompts) && known < len(messages) &&
+			prompts[known].Role == messages[known].Role && prompts[known].Content == messages[known].Content {
+			known++
+		}
+		messages = append(messages, prompts[known:]...)
+		messages = append(messages, conversationMessages(span, "gen_ai.completion.")...)
+	}
+	return messages
+}
+
+// GetSessionSpanCounts returns the span count and error span count of the
+// given sessions, keyed by session ID
+func (h Handler) GetSessionSpanCounts(sessionIDs []string) (map[string]models.SessionSpanCount, error) {
+	counts := make(map[string]models.SessionSpanCount, len(sessionIDs))
+	if len(sessionIDs) == 0 {
+		return counts, nil
+	}
+
+	var results []models.SessionSpanCount
+	result := h.DB.
+		Table("otel_traces").
+		Select(h.SessionID.Column() + ` AS ID,
+			COUNT(*) AS SpanCount,
+			countIf(StatusCode IN ('Error', 'STATUS_CODE_ERROR')) AS ErrorCount
+		`).
+		Where(h.SessionID.Column()+" IN ?", sessionIDs).
+		Group(h.SessionID.Column()).
+		Find(&results)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	for _, count := range results {
+		counts[count.ID] = count
+	}
+	return counts, nil
+}
+
+// CheckSessionsExist splits the given session IDs into the ones that have
+// traces and the ones that do not, keeping the input order
+func (h Handler) CheckSessionsExist(sessionIDs []string) (present []string, missing []string, err error) {
+	if len(sessionIDs) == 0 {
+		return []string{}, []string{}, nil
+	}
+
+	var found []string
+	result := h.DB.
+		Table("otel_traces").
+		Select(h.SessionID.Column()+" AS ID").
+		Where(h.SessionID.Column()+" IN ?", sessionIDs).
+		Group(h.SessionID.Column()).
+		Scan(&found)
+
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+
+	present, missing = splitSessionIDs(sessionIDs, found)
+	return present, missing, nil
+}
+
+// splitSessionIDs splits the requested session IDs, without duplicates, into
+// the ones that were found and the ones that were not
+func splitSessionIDs(requested, found []string) (present []string, missing []string) {
+	present, missing = []string{}, []string{}
+	exists := make(map[string]bool, len(found))
+	for _, id := range found {
+		exists[id] = true
+	}
+	seen := make(map[string]bool, len(requested))
+	for _, id := range requested {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if exists[id] {
+			present = append(present, id)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	return present, missing
+}
+
+// GetSessionIDSUniqueWithPagination returns a page of unique session IDs,
+// newest first, with the total number of matching sessions. Without a cursor
+// the page is read at OFFSET page*limit. With the cursor returned by the
+// previous page it starts right after the last session seen, which stays fast
+// on deep pages. The total is only counted without a cursor, since counting
+// scans every session again, and is 0 on cursor pages. nextCursor is empty on
+// the last page
+func (h Handler) GetSessionIDSUniqueWithPagination(startTime, endTime time.Time, page, limit int, nameFilter *string, cursor string) (sessionIDs []models.SessionUniqueID, total int, nextCursor string, err error) {
+	var last *sessionCursor
+	if cursor != "" {
+		if last, err = decodeSessionCursor(cursor); err != nil {
+			return sessionIDs, 0, "", err
+		}
+	}
+
 	baseQuery := h.DB.
 		Table("otel_traces").
-		Select(`
-			splitByChar('_', SpanAttributes['session.id'])[2] as ID,
+		Select(h.SessionID.Column() + ` as ID,
             MIN(Timestamp) as StartTimestamp
 		`).
 		Where("has(SpanAttributes, 'session.id') = 1").
-		Where("SpanAttributes['session.id'] != ''").
+		Where(h.SessionID.Column() + " != ''").
 		Where("Timestamp >= ? AND Timestamp <= ?", startTime, endTime)
 
 	if nameFilter != nil && *nameFilter != "" {
-		baseQuery = baseQuery.Where("SpanAttributes['session.id'] LIKE ?", *nameFilter+"%")
+		baseQuery = baseQuery.Where(h.SessionID.Column()+" LIKE ?", *nameFilter+"%")
+	}
+
+	// Get paginated results, the session ID breaks ties between sessions
+	// starting at the same time so that no session is skipped or repeated
+	pageQuery := baseQuery.Group(h.SessionID.Column())
+	if last != nil {
+		pageQuery = pageQuery.Having("(MIN(Timestamp), "+h.SessionID.Column()+") < (parseDateTime64BestEffort(?, 9), ?)", last.StartTimestamp, last.ID)
+	} else {
+		pageQuery = pageQuery.Offset(page * limit)
+	}
+	result := pageQuery.
+		Order("StartTimestamp DESC, ID DESC").
+		Limit(limit + 1).
+		Find(&sessionIDs)
+
+	if result.Error != nil {
+		return sessionIDs, 0, "", result.Error
+	}
+	sessionIDs, nextCursor = nextSessionCursor(sessionIDs, limit)
+	if last != nil {
+		return sessionIDs, 0, nextCursor, nil
 	}
 
 	// Get total count
 	var totalCount int64
-	countQuery := baseQuery.Group("splitByChar('_', SpanAttributes['session.id'])[2]")
+	countQuery := baseQuery.Group(h.SessionID.Column())
 	if err := h.DB.Table("(?) as sub", countQuery).Count(&totalCount).Error; err != nil {
-		return sessionIDs, 0, err
+		return sessionIDs, 0, nextCursor, err
 	}
 	total = int(totalCount)
 
-	// Get paginated results
-	offset := page * limit
-	result := baseQuery.
-		Group("splitByChar('_', SpanAttributes['session.id'])[2]").
-		Order("StartTimestamp DESC").
-		Offset(offset).
-		Limit(limit).
-		Find(&sessionIDs)
+	return sessionIDs, total, nextCursor, nil
+}
 
-	if result.Error != nil {
-		return sessionIDs, total, result.Error
+// sessionCursor is the last session of a page, the next page starts after it
+type sessionCursor struct {
+	StartTimestamp string `json:"start_timestamp"`
+	ID             string `json:"id"`
+}
+
+func encodeSessionCursor(session models.SessionUniqueID) string {
+	data, _ := json.Marshal(sessionCursor{StartTimestamp: session.StartTimestamp, ID: session.ID})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeSessionCursor(cursor string) (*sessionCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, models.ErrInvalidCursor
+	}
+	var decoded sessionCursor
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded.ID == "" {
+		return nil, models.ErrInvalidCursor
+	}
+	if _, err := time.Parse(time.RFC3339Nano, decoded.StartTimestamp); err != nil {
+		return nil, models.ErrInvalidCursor
+	}
+	return &decoded, nil
+}
+
+// nextSessionCursor trims a page read with one extra row back to limit and
+// returns the cursor of its last session when more sessions follow
+func nextSessionCursor(sessionIDs []models.SessionUniqueID, limit int) ([]models.SessionUniqueID, string) {
+	if len(sessionIDs) <= limit || limit <= 0 {
+		return sessionIDs, ""
 	}
-	return sessionIDs, total, nil
+	sessionIDs = sessionIDs[:limit]
+	return sessionIDs, encodeSessionCursor(sessionIDs[limit-1])
 }
 
 // GetSessionIDSWithPromptsWithPagination returns unique session IDs with prompts, paginated
 func (h Handler) GetSessionIDSWithPromptsWithPagination(startTime, endTime time.Time, page, limit int, nameFilter *string) (sessionIDs []models.SessionUniqueID, total int, err error) {
     baseQuery := h.DB.
         Table("otel_traces").
-        Select(`
-            splitByChar('_', SpanAttributes['session.id'])[2] as ID,
+        Select(h.SessionID.Column() + ` as ID,
             MIN(Timestamp) as StartTimestamp,
             argMin(
                 SpanAttributes['gen_ai.prompt.0.content'],
@@ -125,17 +333,17 @@ func (h Handler) GetSessionIDSWithPromptsWithPagination(startTime, endTime time.
             ) AS Prompt
         `).
         Where("has(SpanAttributes, 'session.id') = 1").
-        Where("SpanAttributes['session.id'] != ''").
+        Where(h.SessionID.Column() + " != ''").
         Where("SpanAttributes['gen_ai.prompt.0.role'] = 'user'").
         Where("Timestamp >= ? AND Timestamp <= ?", startTime, endTime)
 
     if nameFilter != nil && *nameFilter != "" {
-        baseQuery = baseQuery.Where("SpanAttributes['session.id'] LIKE ?", *nameFilter+"%")
+        baseQuery = baseQuery.Where(h.SessionID.Column()+" LIKE ?", *nameFilter+"%")
     }
 
     // Get total count
     var totalCount int64
-    countQuery := baseQuery.Group("splitByChar('_', SpanAttributes['session.id'])[2]")
+    countQuery := baseQuery.Group(h.SessionID.Column())
     if err := h.DB.Table("(?) as sub", countQuery).Count(&totalCount).Error; err != nil {
         return sessionIDs, 0, err
     }
@@ -144,7 +352,7 @@ func (h Handler) GetSessionIDSWithPromptsWithPagination(startTime, endTime time.
     // Get paginated results
     offset := page * limit
     result := baseQuery.
-        Group("splitByChar('_', SpanAttributes['session.id'])[2]").
+        Group(h.SessionID.Column()).
         Order("StartTimestamp DESC").
         Offset(offset).
         Limit(limit).
@@ -160,7 +368,7 @@ func (h Handler) GetTracesForSessionID(sessionID string) ([]string, error) {
 	var traceIds []string
 
 	query := h.DB.Table("otel_traces").Select("TraceId").Distinct()
-	result := query.Where("splitByChar('_', SpanAttributes['session.id'])[2] = ?", sessionID).Order("Timestamp DESC").
+	result := h.SessionID.Where(query, sessionID).Order("Timestamp DESC").
 		Find(&traceIds)
 
 	if result.Error != nil {
@@ -0,0 +1,109 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/common"
+)
+
+// SessionIDStrategy decides how the session id is extracted from SpanAttributes['session.id']
+//
+//   - raw: the attribute is the session id, lookups match it by suffix
+//   - split: the session id is the second '_' separated part of the attribute
+//   - regex: the session id is the first capture group (or the whole match) of a regex
+type SessionIDStrategy struct {
+	name  string
+	regex *regexp.Regexp
+}
+
+// NewSessionIDStrategy validates the strategy name and, for the regex strategy, its pattern
+func NewSessionIDStrategy(name string, pattern string) (SessionIDStrategy, error) {
+	switch name {
+	case "", common.SESSION_ID_STRATEGY_RAW:
+		return SessionIDStrategy{name: common.SESSION_ID_STRATEGY_RAW}, nil
+	case common.SESSION_ID_STRATEGY_SPLIT:
+		return SessionIDStrategy{name: name}, nil
+	case common.SESSION_ID_STRATEGY_REGEX:
+		if pattern == "" {
+			return SessionIDStrategy{}, fmt.Errorf("session id regex cannot be empty")
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return SessionIDStrategy{}, fmt.Errorf("invalid session id regex: %w", err)
+		}
+		return SessionIDStrategy{name: name, regex: re}, nil
+	default:
+		return SessionIDStrategy{}, fmt.Errorf("unknown session id strategy %q", name)
+	}
+}
+
+// Name returns the strategy name, the zero value is the raw strategy
+func (s SessionIDStrategy) Name() string {
+	if s.name == "" {
+		return common.SESSION_ID_STRATEGY_RAW
+	}
+	return s.name
+}
+
+// Regex returns the regex pattern, empty unless the regex strategy is used
+func (s SessionIDStrategy) Regex() string {
+	if s.regex == nil {
+		return ""
+	}
+	return s.regex.String()
+}
+
+// Column returns the ClickHouse expression extracting the session id
+func (s SessionIDStrategy) Column() string {
+	switch s.Name() {
+	case common.SESSION_ID_STRATEGY_SPLIT:
+		return "splitByChar('_', SpanAttributes['session.id'])[2]"
+	case common.SESSION_ID_STRATEGY_REGEX:
+		return "extract(SpanAttributes['session.id'], " + quoteString(s.regex.String()) + ")"
+	default:
+		return "SpanAttributes['session.id']"
+	}
+}
+
+// Where restricts the query to the spans of the given session
+func (s SessionIDStrategy) Where(query *gorm.DB, sessionID string) *gorm.DB {
+	if s.Name() == common.SESSION_ID_STRATEGY_RAW {
+		return query.Where("SpanAttributes['session.id'] LIKE ?", "%"+sessionID)
+	}
+	return query.Where(s.Column()+" = ?", sessionID)
+}
+
+// Extract returns the session id for a raw session.id attribute value, mirroring Column
+func (s SessionIDStrategy) Extract(raw string) string {
+	switch s.Name() {
+	case common.SESSION_ID_STRATEGY_SPLIT:
+		parts := strings.Split(raw, "_")
+		if len(parts) < 2 {
+			return ""
+		}
+		return parts[1]
+	case common.SESSION_ID_STRATEGY_REGEX:
+		match := s.regex.FindStringSubmatch(raw)
+		if match == nil {
+			return ""
+		}
+		if len(match) > 1 {
+			return match[1]
+		}
+		return match[0]
+	default:
+		return raw
+	}
+}
+
+// quoteString returns value as a ClickHouse string literal
+func quoteString(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value) + "'"
+}
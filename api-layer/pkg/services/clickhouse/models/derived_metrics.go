@@ -8,6 +8,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -58,6 +60,64 @@ func (j *JSONRawMessage) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Keys returns the sorted top-level keys of the JSON object
+func (j JSONRawMessage) Keys() ([]string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(j, &fields); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// WithoutKeys returns a copy of the JSON object without the given top-level keys
+func (j JSONRawMessage) WithoutKeys(keys []string) (JSONRawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(j, &fields); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		delete(fields, key)
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	return JSONRawMessage(data), nil
+}
+
+// Typed returns a copy of the JSON object where top-level string values holding
+// a JSON number are replaced by that number. Other values, and JSON that is not
+// an object, are returned unchanged
+func (j JSONRawMessage) Typed() JSONRawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(j, &fields); err != nil {
+		return j
+	}
+
+	for key, value := range fields {
+		var text string
+		if err := json.Unmarshal(value, &text); err != nil || text == "" || strings.TrimSpace(text) != text {
+			continue
+		}
+		var number json.Number
+		if err := json.Unmarshal([]byte(text), &number); err == nil {
+			fields[key] = json.RawMessage(number)
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return j
+	}
+	return JSONRawMessage(data)
+}
+
 // OtelTraces represents an Otel tracing span in ClickHouse
 type Metric struct {
 	ID        *string         `json:"id" gorm:"column:ID;type:String;primaryKey;not null"`
@@ -71,6 +131,127 @@ type Metric struct {
 	Scope     *string         `json:"-" gorm:"column:Scope;type:String;not null"`
 }
 
+// AppInfo identifies an application that has written metrics
+type AppInfo struct {
+	AppName string `json:"app_name" gorm:"column:AppName"`
+	AppId   string `json:"app_id" gorm:"column:AppId"`
+}
+
+// SpanMetricCount is the number of metrics written for a span
+type SpanMetricCount struct {
+	SpanId      string `json:"span_id" gorm:"column:SpanId"`
+	MetricCount uint64 `json:"metric_count" gorm:"column:MetricCount"`
+}
+
+// MetricMatrix holds the average of each requested metric key per app
+type MetricMatrix struct {
+	Keys []string          `json:"keys"`
+	Apps []MetricMatrixRow `json:"apps"`
+}
+
+// MetricMatrixRow holds the averages of one app by metric key, null when the
+// app has no numeric value for the key
+type MetricMatrixRow struct {
+	AppName string              `json:"app_name"`
+	Values  map[string]*float64 `json:"values"`
+}
+
+// ErrIncompleteUpsert is returned when a metric upsert lacks a field of its key
+var ErrIncompleteUpsert = errors.New("upsert requires session_id, scope and metrics, and span_id for span metrics")
+
+// ErrNoMetricValues is returned when no numeric metric value could be aggregated
+var ErrNoMetricValues = errors.New("no numeric metric values found")
+
+// MetricRollup is a span metric aggregated over a session
+type MetricRollup struct {
+	SessionID string  `json:"session_id"`
+	Key       string  `json:"key"`
+	Agg       string  `json:"agg"`
+	Value     float64 `json:"value"`
+}
+
+// MetricComparison holds the value of a metric key in two sessions. A value is
+// null when the key is missing from that session, and Delta (B - A) is only
+// set when both values are numeric
+type MetricComparison struct {
+	SessionA json.RawMessage `json:"session_a" swaggertype:"string"`
+	SessionB json.RawMessage `json:"session_b" swaggertype:"string"`
+	Delta    *float64        `json:"delta,omitempty"`
+}
+
+// MetricComparisonResponse represents the response of the /metrics/compare endpoint
+type MetricComparisonResponse struct {
+	SessionA string                      `json:"session_a"`
+	SessionB string                      `json:"session_b"`
+	Scope    string                      `json:"scope"`
+	Metrics  map[string]MetricComparison `json:"metrics"`
+}
+
+// LatestMetricValues collapses metric rows, sorted newest first, into the most
+// recent value of each top-level key. Rows whose metrics are not a JSON object
+// are skipped
+func LatestMetricValues(metrics []Metric) map[string]json.RawMessage {
+	values := make(map[string]json.RawMessage)
+	for _, metric := range metrics {
+		if metric.Metrics == nil {
+			continue
+		}
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(*metric.Metrics, &fields); err != nil {
+			continue
+		}
+		for key, value := range fields {
+			if _, ok := values[key]; !ok {
+				values[key] = value
+			}
+		}
+	}
+	return values
+}
+
+// CompareMetrics merges the latest metric values of two sessions, given newest
+// first, by key. Keys present in only one session are kept with a null value
+// for the other one
+func CompareMetrics(a, b []Metric) map[string]MetricComparison {
+	valuesA, valuesB := LatestMetricValues(a), LatestMetricValues(b)
+
+	comparison := make(map[string]MetricComparison, len(valuesA)+len(valuesB))
+	for key, value := range valuesA {
+		comparison[key] = MetricComparison{SessionA: value}
+	}
+	for key, value := range valuesB {
+		entry := comparison[key]
+		entry.SessionB = value
+		comparison[key] = entry
+	}
+
+	for key, entry := range comparison {
+		numberA, okA := metricNumber(entry.SessionA)
+		numberB, okB := metricNumber(entry.SessionB)
+		if okA && okB {
+			delta := numberB - numberA
+			entry.Delta = &delta
+			comparison[key] = entry
+		}
+	}
+	return comparison
+}
+
+// metricNumber reads a metric value that is a JSON number or a string holding
+// one, as Typed does
+func metricNumber(value json.RawMessage) (float64, bool) {
+	if value == nil {
+		return 0, false
+	}
+	// json.Number also accepts a quoted number
+	var number json.Number
+	if err := json.Unmarshal(value, &number); err != nil {
+		return 0, false
+	}
+	f, err := number.Float64()
+	return f, err == nil
+}
+
 // MetricCreateRequest represents the request payload for creating a metric (without ID and timestamp)
 type MetricCreateRequest struct {
 	SpanId    *string         `json:"span_id" binding:"required"`
@@ -142,6 +323,12 @@ func (m *Metric) BeforeCreate(tx *gorm.DB) error {
 	now := time.Now()
 	m.TimeStamp = &now
 
+	// SpanId is not nullable, session metrics without a span store it empty
+	if m.SpanId == nil && m.Scope != nil && *m.Scope == "session" {
+		noSpan := ""
+		m.SpanId = &noSpan
+	}
+
 	// Check if all required fields are present (not empty/nil)
 	if m.isEmptyReflection() {
 		return errors.New("cannot create Metric: required fields are empty")
@@ -152,8 +339,8 @@ func (m *Metric) BeforeCreate(tx *gorm.DB) error {
 
 // isEmptyReflection checks if critical fields are empty/nil
 func (m *Metric) isEmptyReflection() bool {
-	// Check critical required fields
-	if m.SpanId == nil || *m.SpanId == "" {
+	// Check critical required fields, session metrics may have no span
+	if (m.SpanId == nil || *m.SpanId == "") && (m.Scope == nil || *m.Scope != "session") {
 		return true
 	}
 	if m.TraceId == nil || *m.TraceId == "" {
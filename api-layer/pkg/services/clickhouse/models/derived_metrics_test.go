@@ -0,0 +1,146 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRawMessageKeys(t *testing.T) {
+	t.Run("Object keys should be returned sorted", func(t *testing.T) {
+		keys, err := JSONRawMessage(`{"latency_ms":"120","accuracy":"0.95"}`).Keys()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"accuracy", "latency_ms"}, keys)
+	})
+
+	t.Run("Empty object should return no keys", func(t *testing.T) {
+		keys, err := JSONRawMessage(`{}`).Keys()
+		assert.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("Non-object JSON should return an error", func(t *testing.T) {
+		_, err := JSONRawMessage(`["accuracy"]`).Keys()
+		assert.Error(t, err)
+	})
+}
+
+func TestJSONRawMessageWithoutKeys(t *testing.T) {
+	t.Run("Given keys should be removed", func(t *testing.T) {
+		filtered, err := JSONRawMessage(`{"accuracy":"0.95","latency_ms":120,"debug":{"a":1}}`).WithoutKeys([]string{"debug", "missing"})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"accuracy":"0.95","latency_ms":120}`, string(filtered))
+	})
+
+	t.Run("Non-object JSON should return an error", func(t *testing.T) {
+		_, err := JSONRawMessage(`["accuracy"]`).WithoutKeys([]string{"accuracy"})
+		assert.Error(t, err)
+	})
+}
+
+func TestJSONRawMessageTyped(t *testing.T) {
+	t.Run("Numeric strings should become numbers and other values stay as is", func(t *testing.T) {
+		typed := JSONRawMessage(`{
+			"accuracy": "0.95",
+			"latency_ms": "120",
+			"score": "-1.5e3",
+			"already_number": 3,
+			"cache_hit": "true",
+			"error_type": "timeout",
+			"padded": " 42",
+			"leading_zero": "007",
+			"empty": "",
+			"nested": {"value": "1"},
+			"flag": false
+		}`).Typed()
+		assert.JSONEq(t, `{
+			"accuracy": 0.95,
+			"latency_ms": 120,
+			"score": -1.5e3,
+			"already_number": 3,
+			"cache_hit": "true",
+			"error_type": "timeout",
+			"padded": " 42",
+			"leading_zero": "007",
+			"empty": "",
+			"nested": {"value": "1"},
+			"flag": false
+		}`, string(typed))
+	})
+
+	t.Run("Non-object JSON should be returned unchanged", func(t *testing.T) {
+		assert.Equal(t, JSONRawMessage(`["0.95"]`), JSONRawMessage(`["0.95"]`).Typed())
+	})
+}
+
+func TestCompareMetrics(t *testing.T) {
+	metric := func(metrics string) Metric {
+		raw := JSONRawMessage(metrics)
+		return Metric{Metrics: &raw}
+	}
+
+	t.Run("Shared keys should hold both values and a numeric delta", func(t *testing.T) {
+		comparison := CompareMetrics(
+			[]Metric{metric(`{"latency_ms":"120","accuracy":0.5}`)},
+			[]Metric{metric(`{"latency_ms":"100","accuracy":"0.75"}`)},
+		)
+
+		assert.Len(t, comparison, 2)
+		assert.JSONEq(t, `"120"`, string(comparison["latency_ms"].SessionA))
+		assert.JSONEq(t, `"100"`, string(comparison["latency_ms"].SessionB))
+		if assert.NotNil(t, comparison["latency_ms"].Delta) {
+			assert.Equal(t, -20.0, *comparison["latency_ms"].Delta)
+		}
+		if assert.NotNil(t, comparison["accuracy"].Delta) {
+			assert.Equal(t, 0.25, *comparison["accuracy"].Delta)
+		}
+	})
+
+	t.Run("Divergent keys should be null for the session missing them", func(t *testing.T) {
+		comparison := CompareMetrics(
+			[]Metric{metric(`{"only_a":"1","shared":"1"}`)},
+			[]Metric{metric(`{"only_b":"2","shared":"3"}`)},
+		)
+
+		assert.Len(t, comparison, 3)
+		assert.JSONEq(t, `"1"`, string(comparison["only_a"].SessionA))
+		assert.Nil(t, comparison["only_a"].SessionB)
+		assert.Nil(t, comparison["only_a"].Delta)
+		assert.Nil(t, comparison["only_b"].SessionA)
+		assert.JSONEq(t, `"2"`, string(comparison["only_b"].SessionB))
+		assert.Nil(t, comparison["only_b"].Delta)
+		if assert.NotNil(t, comparison["shared"].Delta) {
+			assert.Equal(t, 2.0, *comparison["shared"].Delta)
+		}
+
+		data, err := json.Marshal(comparison["only_a"])
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"session_a":"1","session_b":null}`, string(data))
+	})
+
+	t.Run("Non numeric values should not get a delta", func(t *testing.T) {
+		comparison := CompareMetrics(
+			[]Metric{metric(`{"model":"gpt-4","score":" 1"}`)},
+			[]Metric{metric(`{"model":"gpt-5","score":"2"}`)},
+		)
+
+		assert.JSONEq(t, `"gpt-4"`, string(comparison["model"].SessionA))
+		assert.JSONEq(t, `"gpt-5"`, string(comparison["model"].SessionB))
+		assert.Nil(t, comparison["model"].Delta)
+		assert.Nil(t, comparison["score"].Delta)
+	})
+
+	t.Run("Newest row should win for a key written several times", func(t *testing.T) {
+		comparison := CompareMetrics(
+			[]Metric{metric(`{"latency_ms":"90"}`), metric(`{"latency_ms":"120","accuracy":"0.9"}`), {}},
+			nil,
+		)
+
+		assert.JSONEq(t, `"90"`, string(comparison["latency_ms"].SessionA))
+		assert.JSONEq(t, `"0.9"`, string(comparison["accuracy"].SessionA))
+	})
+}
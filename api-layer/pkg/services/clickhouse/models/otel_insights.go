@@ -3,6 +3,14 @@
 
 package models
 
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a session list cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type AgentsUsage struct {
 	SpanName   string `json:"agent_name"`
 	UsageCount int    `json:"usage_count"`
@@ -13,6 +21,30 @@ type AgentsTokenUsage struct {
 	TotalTokens int    `json:"total_tokens"`
 }
 
+// SessionTokenUsage is the total number of LLM tokens used by a session
+type SessionTokenUsage struct {
+	SessionID   string `json:"session_id"`
+	TotalTokens int64  `json:"total_tokens"`
+}
+
+// SessionTokenUsageResponse represents the paginated response for /insights/tokens/sessions endpoint
+type SessionTokenUsageResponse struct {
+	Data  []SessionTokenUsage `json:"data"`
+	Total int                 `json:"total"`
+}
+
+// GraphDeterminism measures how often the sessions of an app follow the same
+// execution path, the time-ordered sequence of their span names. Score is the
+// fraction of sessions following the dominant path
+type GraphDeterminism struct {
+	AppName           string   `json:"app_name"`
+	SessionCount      int      `json:"session_count"`
+	UniquePathCount   int      `json:"unique_path_count"`
+	DominantPath      []string `json:"dominant_path"`
+	DominantPathCount int      `json:"dominant_path_count"`
+	Score             float64  `json:"score"`
+}
+
 type ResponseLatencyPerAgent struct {
 	ServiceName   string  `json:"service_name"`
 	TotalRequests int     `json:"total_requests"`
@@ -22,6 +54,14 @@ type ResponseLatencyPerAgent struct {
 	MinLatency    float64 `json:"min_latency"`
 }
 
+type SpanDurationStat struct {
+	SpanName      string  `json:"span_name"`
+	Count         int     `json:"count"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+	MaxDurationMs float64 `json:"max_duration_ms"`
+	P95DurationMs float64 `json:"p95_duration_ms"`
+}
+
 type CallGraph struct {
 	PreviousSpan string `json:"previous_span"`
 	CurrentSpan  string `json:"current_span"`
@@ -29,6 +69,14 @@ type CallGraph struct {
 	Timestamp    string `json:"timestamp"`
 }
 
+// CallGraphEdge is a directed transition between two consecutive spans and the
+// number of times it occurs
+type CallGraphEdge struct {
+	FromSpan string `json:"from_span"`
+	ToSpan   string `json:"to_span"`
+	Count    uint64 `json:"count"`
+}
+
 type SessionID struct {
 	ID          string `json:"id"`
 	SpanName    string `json:"name"`
@@ -38,9 +86,42 @@ type SessionID struct {
 }
 
 type SessionUniqueID struct {
-	ID             string `json:"id"`
-	StartTimestamp string `json:"start_timestamp"`
-    Prompt         string `json:"prompt,omitempty"`
+	ID             string  `json:"id"`
+	StartTimestamp string  `json:"start_timestamp"`
+	Prompt         string  `json:"prompt,omitempty"`
+	SpanCount      *uint64 `json:"span_count,omitempty" gorm:"-"`
+	ErrorCount     *uint64 `json:"error_count,omitempty" gorm:"-"`
+}
+
+// ConversationMessage is a gen_ai prompt or completion message of a session
+type ConversationMessage struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	SpanId    string    `json:"span_id"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SessionConversation is the chronological list of gen_ai messages of a session
+type SessionConversation struct {
+	SessionID string                `json:"session_id"`
+	Messages  []ConversationMessage `json:"messages"`
+}
+
+// SessionSpanCount holds the number of spans and error spans of a session
+type SessionSpanCount struct {
+	ID         string
+	SpanCount  uint64
+	ErrorCount uint64
+}
+
+// TraceStats summarizes the spans of a trace. TotalDurationMs spans from the
+// start of the first span to the end of the last one
+type TraceStats struct {
+	TraceId         string   `json:"trace_id"`
+	SpanCount       int      `json:"span_count"`
+	ErrorSpanCount  int      `json:"error_span_count"`
+	Services        []string `json:"services"`
+	TotalDurationMs float64  `json:"total_duration_ms"`
 }
 
 type TraceId struct {
@@ -53,10 +134,33 @@ type AGPMetrics struct {
 	Timestamp  string            `json:"timestamp"`
 }
 
-// SessionsResponse represents the paginated response for /traces/sessions endpoint
+// SessionsResponse represents the paginated response for /traces/sessions endpoint.
+// Total is omitted on pages read with a cursor
 type SessionsResponse struct {
-	Data  []SessionUniqueID `json:"data"`
-	Total int               `json:"total"`
+	Data       []SessionUniqueID `json:"data"`
+	Total      *int              `json:"total,omitempty"`
+	NextCursor string            `json:"next_cursor,omitempty"`
+}
+
+// SessionsExistRequest represents the request payload for /traces/sessions/exists endpoint
+type SessionsExistRequest struct {
+	SessionIDs []string `json:"session_ids"`
+}
+
+// SessionsExistResponse represents the response for /traces/sessions/exists endpoint
+type SessionsExistResponse struct {
+	Present []string `json:"present"`
+	Missing []string `json:"missing"`
+}
+
+// PurgeResult reports how many rows of each table were targeted when purging a
+// session. Failed lists the tables whose delete failed, with the error, and is
+// empty when the whole session was purged
+type PurgeResult struct {
+	SessionID string           `json:"session_id"`
+	Deleted   map[string]int64 `json:"deleted"`
+	Failed    []string         `json:"failed,omitempty"`
+	Error     string           `json:"error,omitempty"`
 }
 
 // SessionSpansResponse represents the response for /traces/sessions/spans endpoint
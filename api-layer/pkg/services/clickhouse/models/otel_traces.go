@@ -3,7 +3,91 @@
 
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
+)
+
+// AttributeMap is a string map that tolerates ClickHouse attribute maps whose
+// values are not strings, converting them instead of failing the whole scan
+type AttributeMap map[string]string
+
+// Scan implements the sql.Scanner interface for reading from database
+func (m *AttributeMap) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*m = nil
+		return nil
+	case map[string]string:
+		*m = AttributeMap(v)
+		return nil
+	case string:
+		return m.scanJSON([]byte(v))
+	case []byte:
+		return m.scanJSON(v)
+	}
+
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Map || rv.Type().Key().Kind() != reflect.String {
+		logger.Zap.Warn("Ignoring attribute map with unsupported type", logger.String("type", fmt.Sprintf("%T", value)))
+		*m = nil
+		return nil
+	}
+
+	attributes := make(AttributeMap, rv.Len())
+	iter := rv.MapRange()
+	for iter.Next() {
+		attributes[iter.Key().String()] = attributeString(iter.Key().String(), iter.Value().Interface())
+	}
+	*m = attributes
+	return nil
+}
+
+// scanJSON reads an attribute map serialized as a JSON object
+func (m *AttributeMap) scanJSON(data []byte) error {
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		logger.Zap.Warn("Ignoring attribute map that is not a JSON object", logger.Error(err))
+		*m = nil
+		return nil
+	}
+
+	attributes := make(AttributeMap, len(values))
+	for key, value := range values {
+		attributes[key] = attributeString(key, value)
+	}
+	*m = attributes
+	return nil
+}
+
+// attributeString converts an attribute value to a string, logging a warning
+// when the value was not stored as a string
+func attributeString(key string, value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case *string:
+		if v != nil {
+			return *v
+		}
+		return ""
+	case nil:
+		return ""
+	case []byte:
+		return string(v)
+	}
+
+	logger.Zap.Warn("Converting non-string attribute value", logger.String("key", key), logger.String("type", fmt.Sprintf("%T", value)))
+	if data, err := json.Marshal(value); err == nil {
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
 
 // OtelTraces represents an Otel tracing span in ClickHouse
 type OtelTraces struct {
@@ -15,10 +99,10 @@ type OtelTraces struct {
 	SpanName           string              `gorm:"column:SpanName;type:LowCardinality(String)"`
 	SpanKind           string              `gorm:"column:SpanKind;type:LowCardinality(String)"`
 	ServiceName        string              `gorm:"column:ServiceName;type:LowCardinality(String)"`
-	ResourceAttributes map[string]string   `gorm:"column:ResourceAttributes;type:Map(LowCardinality(String), String)"`
+	ResourceAttributes AttributeMap        `gorm:"column:ResourceAttributes;type:Map(LowCardinality(String), String)"`
 	ScopeName          string              `gorm:"column:ScopeName;type:String"`
 	ScopeVersion       string              `gorm:"column:ScopeVersion;type:String"`
-	SpanAttributes     map[string]string   `gorm:"column:SpanAttributes;type:Map(LowCardinality(String), String)"`
+	SpanAttributes     AttributeMap        `gorm:"column:SpanAttributes;type:Map(LowCardinality(String), String)"`
 	Duration           uint64              `gorm:"column:Duration;type:UInt64"`
 	StatusCode         string              `gorm:"column:StatusCode;type:LowCardinality(String)"`
 	StatusMessage      string              `gorm:"column:StatusMessage;type:String"`
@@ -31,7 +115,120 @@ type OtelTraces struct {
 	LinksAttributes    []map[string]string `gorm:"column:Links.Attributes;type:Array(Map(LowCardinality(String), String))"`
 }
 
+// SpanEvent is a single event of a span, read from the parallel Events arrays
+type SpanEvent struct {
+	TraceId    string            `json:"trace_id"`
+	SpanId     string            `json:"span_id"`
+	SpanName   string            `json:"span_name"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Name       string            `json:"name"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+// Events unrolls the parallel Events arrays of the span into one SpanEvent per
+// event. Arrays shorter than Events.Name leave the matching fields empty
+func (t OtelTraces) Events() []SpanEvent {
+	events := make([]SpanEvent, 0, len(t.EventsName))
+	for i, name := range t.EventsName {
+		event := SpanEvent{
+			TraceId:  t.TraceId,
+			SpanId:   t.SpanId,
+			SpanName: t.SpanName,
+			Name:     name,
+		}
+		if i < len(t.EventsTimestamp) {
+			event.Timestamp = t.EventsTimestamp[i]
+		}
+		if i < len(t.EventsAttributes) {
+			event.Attributes = t.EventsAttributes[i]
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
 // TableName overrides the table name in GORM
 func (OtelTraces) TableName() string {
 	return "otel_traces"
 }
+
+// TraceField describes a column of the otel_traces table and the key of the
+// matching field in trace responses
+type TraceField struct {
+	Field   string `json:"field"`
+	Column  string `json:"column"`
+	JSONKey string `json:"json_key"`
+	Type    string `json:"type"`
+}
+
+// OtelTracesSchema returns the fields of OtelTraces in declaration order, with
+// the column and ClickHouse type read from their GORM tags
+func OtelTracesSchema() []TraceField {
+	modelType := reflect.TypeOf(OtelTraces{})
+	fields := make([]TraceField, 0, modelType.NumField())
+	for i := 0; i < modelType.NumField(); i++ {
+		structField := modelType.Field(i)
+		field := TraceField{Field: structField.Name, JSONKey: structField.Name}
+
+		for _, setting := range strings.Split(structField.Tag.Get("gorm"), ";") {
+			key, value, _ := strings.Cut(setting, ":")
+			switch key {
+			case "column":
+				field.Column = value
+			case "type":
+				field.Type = value
+			}
+		}
+		if name, _, _ := strings.Cut(structField.Tag.Get("json"), ","); name != "" {
+			field.JSONKey = name
+		}
+
+		fields = append(fields, field)
+	}
+	return fields
+}
+
+// traceFieldIndex maps the JSON key of every OtelTraces field to its index
+var traceFieldIndex = func() map[string]int {
+	index := make(map[string]int)
+	for i, field := range OtelTracesSchema() {
+		index[field.JSONKey] = i
+	}
+	return index
+}()
+
+// ParseTraceFields splits a comma-separated list of trace JSON keys, dropping
+// blanks and duplicates. Keys that are not OtelTraces fields are rejected
+func ParseTraceFields(list string) ([]string, error) {
+	var fields, unknown []string
+	seen := make(map[string]bool)
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" || seen[field] {
+			continue
+		}
+		seen[field] = true
+		if _, ok := traceFieldIndex[field]; !ok {
+			unknown = append(unknown, field)
+			continue
+		}
+		fields = append(fields, field)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown trace fields: %s", strings.Join(unknown, ", "))
+	}
+	return fields, nil
+}
+
+// Project returns the span with only the given fields, keyed by JSON key.
+// Fields are expected to come from ParseTraceFields, unknown ones are skipped
+func (t OtelTraces) Project(fields []string) map[string]interface{} {
+	value := reflect.ValueOf(t)
+	projected := make(map[string]interface{}, len(fields))
+	for _, field := range fields {
+		if i, ok := traceFieldIndex[field]; ok {
+			projected[field] = value.Field(i).Interface()
+		}
+	}
+	return projected
+}
@@ -0,0 +1,154 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeMapScan(t *testing.T) {
+	t.Run("String maps should be kept as is", func(t *testing.T) {
+		var attributes AttributeMap
+		err := attributes.Scan(map[string]string{"session.id": "session_abc123"})
+		assert.NoError(t, err)
+		assert.Equal(t, AttributeMap{"session.id": "session_abc123"}, attributes)
+	})
+
+	t.Run("Non-string values should be converted to strings", func(t *testing.T) {
+		var attributes AttributeMap
+		err := attributes.Scan(map[string]interface{}{
+			"session.id":        "session_abc123",
+			"gen_ai.usage.cost": 0.25,
+			"retry.count":       int64(3),
+			"cache.hit":         true,
+			"tool.args":         []interface{}{"a", 1},
+			"missing":           nil,
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, AttributeMap{
+			"session.id":        "session_abc123",
+			"gen_ai.usage.cost": "0.25",
+			"retry.count":       "3",
+			"cache.hit":         "true",
+			"tool.args":         `["a",1]`,
+			"missing":           "",
+		}, attributes)
+	})
+
+	t.Run("Maps with other value types should be converted", func(t *testing.T) {
+		var attributes AttributeMap
+		err := attributes.Scan(map[string]int32{"http.status_code": 500})
+		assert.NoError(t, err)
+		assert.Equal(t, AttributeMap{"http.status_code": "500"}, attributes)
+	})
+
+	t.Run("JSON objects should be decoded", func(t *testing.T) {
+		var attributes AttributeMap
+		err := attributes.Scan([]byte(`{"session.id":"session_abc123","retry.count":3}`))
+		assert.NoError(t, err)
+		assert.Equal(t, AttributeMap{"session.id": "session_abc123", "retry.count": "3"}, attributes)
+	})
+
+	t.Run("Unsupported values should be ignored without failing", func(t *testing.T) {
+		attributes := AttributeMap{"stale": "value"}
+		assert.NoError(t, attributes.Scan(42))
+		assert.Nil(t, attributes)
+
+		attributes = AttributeMap{"stale": "value"}
+		assert.NoError(t, attributes.Scan("not json"))
+		assert.Nil(t, attributes)
+	})
+
+	t.Run("Nil should reset the map", func(t *testing.T) {
+		attributes := AttributeMap{"stale": "value"}
+		assert.NoError(t, attributes.Scan(nil))
+		assert.Nil(t, attributes)
+	})
+}
+
+func TestOtelTracesEvents(t *testing.T) {
+	t.Run("Parallel event arrays should be unrolled into one event each", func(t *testing.T) {
+		timestamp := time.Date(2023, 6, 25, 15, 30, 0, 0, time.UTC)
+		trace := OtelTraces{
+			TraceId:          "trace_def456",
+			SpanId:           "span_abc123",
+			SpanName:         "agent",
+			EventsTimestamp:  []time.Time{timestamp, timestamp.Add(time.Second)},
+			EventsName:       []string{"tool_call", "tool_result"},
+			EventsAttributes: []map[string]string{{"tool.name": "search"}, {"tool.status": "ok"}},
+		}
+
+		assert.Equal(t, []SpanEvent{
+			{TraceId: "trace_def456", SpanId: "span_abc123", SpanName: "agent", Timestamp: timestamp, Name: "tool_call", Attributes: map[string]string{"tool.name": "search"}},
+			{TraceId: "trace_def456", SpanId: "span_abc123", SpanName: "agent", Timestamp: timestamp.Add(time.Second), Name: "tool_result", Attributes: map[string]string{"tool.status": "ok"}},
+		}, trace.Events())
+	})
+
+	t.Run("Shorter arrays should leave fields empty", func(t *testing.T) {
+		trace := OtelTraces{EventsName: []string{"retry"}}
+
+		assert.Equal(t, []SpanEvent{{Name: "retry"}}, trace.Events())
+	})
+
+	t.Run("Span without events should return none", func(t *testing.T) {
+		assert.Empty(t, OtelTraces{}.Events())
+	})
+}
+
+func TestOtelTracesSchema(t *testing.T) {
+	t.Run("Every model field should be described from its tags", func(t *testing.T) {
+		schema := OtelTracesSchema()
+
+		modelType := reflect.TypeOf(OtelTraces{})
+		require.Len(t, schema, modelType.NumField())
+		for i, field := range schema {
+			assert.Equal(t, modelType.Field(i).Name, field.Field)
+			assert.NotEmpty(t, field.Column, field.Field)
+			assert.NotEmpty(t, field.Type, field.Field)
+			assert.Equal(t, field.Field, field.JSONKey, "OtelTraces has no json tags")
+		}
+
+		assert.Equal(t, TraceField{Field: "Timestamp", Column: "Timestamp", JSONKey: "Timestamp", Type: "DateTime64(9)"}, schema[0])
+		assert.Contains(t, schema, TraceField{Field: "SpanAttributes", Column: "SpanAttributes", JSONKey: "SpanAttributes", Type: "Map(LowCardinality(String), String)"})
+		assert.Contains(t, schema, TraceField{Field: "LinksTraceId", Column: "Links.TraceId", JSONKey: "LinksTraceId", Type: "Array(String)"})
+	})
+}
+
+func TestParseTraceFields(t *testing.T) {
+	t.Run("Known fields should be kept in order without blanks or duplicates", func(t *testing.T) {
+		fields, err := ParseTraceFields(" SpanName, ,Duration,SpanName ")
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"SpanName", "Duration"}, fields)
+	})
+
+	t.Run("Unknown fields should be rejected", func(t *testing.T) {
+		_, err := ParseTraceFields("SpanName,duration,Cost")
+		assert.EqualError(t, err, "unknown trace fields: duration, Cost")
+	})
+}
+
+func TestOtelTracesProject(t *testing.T) {
+	t.Run("Only requested fields should be serialized", func(t *testing.T) {
+		trace := OtelTraces{
+			TraceId:        "trace_def456",
+			SpanName:       "ml_inference",
+			Duration:       1500,
+			SpanAttributes: AttributeMap{"session.id": "session_abc123"},
+		}
+
+		data, err := json.Marshal(trace.Project([]string{"SpanName", "Duration", "SpanAttributes"}))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"SpanName":"ml_inference","Duration":1500,"SpanAttributes":{"session.id":"session_abc123"}}`, string(data))
+	})
+
+	t.Run("No fields should project to an empty object", func(t *testing.T) {
+		assert.Empty(t, OtelTraces{SpanName: "ml_inference"}.Project(nil))
+	})
+}
@@ -0,0 +1,36 @@
+// Copyright AGNTCY Contributors (https://github.com/agntcy)
+// SPDX-License-Identifier: Apache-2.0
+
+package clickhouse
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/logger"
+	"github.com/agntcy/telemetry-hub/api-layer/pkg/services/clickhouse/handlers"
+)
+
+// RunMetricRetention deletes metrics older than retentionDays right away and
+// then on every interval, until the context is cancelled
+func (cs *ClickhouseService) RunMetricRetention(ctx context.Context, wg *sync.WaitGroup, retentionDays int, interval time.Duration) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		cutoff := handlers.RetentionCutoff(time.Now(), retentionDays)
+		if _, err := cs.Handlers.DeleteMetricsBefore(cutoff); err != nil {
+			logger.Zap.Error("Metric retention failed", logger.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Zap.Info("Exit metric retention")
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -38,19 +40,51 @@ import (
 )
 
 type HttpServer struct {
-	Port            int
-	DataService     services.DataService
-	SignalsChannel  chan os.Signal
-	BaseUrl         string
-	AllowOrigins    string
-	httpServer      *http.Server
-	keepAliveMetric prometheus.Counter
+	Port                   int
+	DataService            services.DataService
+	SignalsChannel         chan os.Signal
+	BaseUrl                string
+	AllowOrigins           string
+	ReadOnly               bool
+	StrictJSON             bool
+	SessionIDStrategy      string
+	SessionIDRegex         string
+	MetricKeyAllowlist     map[string][]string
+	MetricKeyAllowlistMode string
+	httpServer             *http.Server
+	keepAliveMetric        prometheus.Counter
 }
 
 type SimpleMessage struct {
 	Message string `json:"message"`
 }
 
+// ResponseEnvelope wraps a successful response when the request sends
+// X-Response-Envelope: true
+type ResponseEnvelope struct {
+	Data  interface{} `json:"data"`
+	Error *string     `json:"error"`
+}
+
+// ComponentStatus reports the availability of a backing service
+type ComponentStatus struct {
+	Status   string `json:"status"`
+	Required bool   `json:"required"`
+	Error    string `json:"error,omitempty"`
+}
+
+// ReadyResponse reports the readiness of the server and of each component
+type ReadyResponse struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// InfoResponse describes the active server configuration
+type InfoResponse struct {
+	SessionIDStrategy string `json:"session_id_strategy"`
+	SessionIDRegex    string `json:"session_id_regex,omitempty"`
+}
+
 type SessionID models.SessionUniqueID
 type Trace models.OtelTraces
 type CreateMetric models.MetricCreateRequest
@@ -69,10 +103,14 @@ type EchoResponse map[string]interface{}
 // @Tags         APIs
 // @Accept       json
 // @Produce      json
-// @Param        start_time query string true "Start time in ISO 8601 UTC format (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T15:04:05Z")
-// @Param        end_time query string true "End time in ISO 8601 UTC format (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T18:04:05Z")
+// @Param        start_time query string true "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T15:04:05Z")
+// @Param        end_time query string true "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T18:04:05Z")
+// @Param        include_counts query bool false "Add the span count and error span count of each session"
+// @Param        page query int false "Page number starting at 0, enables pagination (default 0)"
+// @Param        limit query int false "Page size, enables pagination (default 100, max 1000)"
+// @Param        cursor query string false "next_cursor of the previous page, enables pagination and takes precedence over page. The response omits total"
 // @Success		 200 {array} models.SessionsResponse "list of session IDs"
-// @Failure      400 {object} string "Bad request"
+// @Failure      400 {object} ErrorResponse "Bad request"
 // @Failure      500 {object} string "Internal server error"
 // @Router       /traces/sessions [get]
 func (hs *HttpServer) Sessions(w http.ResponseWriter, r *http.Request) {
@@ -81,39 +119,76 @@ func (hs *HttpServer) Sessions(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	startTime := r.URL.Query().Get(common.START_TIME)
-	startTimeParsed, err := common.ParseTime(startTime)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid start_time: %v", err), http.StatusBadRequest)
-		return
-	}
+	params := newQueryParams(r)
+	startTimeParsed := params.requiredTime(common.START_TIME)
+	endTimeParsed := params.requiredTime(common.END_TIME)
 
-	endTime := r.URL.Query().Get(common.END_TIME)
-	endTimeParsed, err := common.ParseTime(endTime)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid end_time: %v", err), http.StatusBadRequest)
+	query := r.URL.Query()
+	cursor := query.Get(common.CURSOR)
+	paginated := cursor != "" || query.Get(common.PAGE) != "" || query.Get(common.LIMIT) != ""
+	includePrompts := query.Get(common.INCLUDE_PROMPTS)
+	if paginated && includePrompts == "true" {
+		params.fail("include_prompts cannot be combined with page, limit or cursor")
+	}
+	var page, limit int
+	if paginated {
+		page, limit = params.sessionPage()
+	}
+	if !params.valid(w) {
 		return
 	}
 
-	includePrompts := r.URL.Query().Get(common.INCLUDE_PROMPTS)
 	var sessionIDs []models.SessionUniqueID
-    if includePrompts == "true" {
-        sessionIDs, err = hs.DataService.GetSessionIDSWithPrompts(startTimeParsed, endTimeParsed)
-    } else {
-        sessionIDs, err = hs.DataService.GetSessionIDSUnique(startTimeParsed, endTimeParsed)
-    }
+	var total int
+	var nextCursor string
+	var err error
+	switch {
+	case paginated:
+		sessionIDs, total, nextCursor, err = hs.DataService.GetSessionIDSUniqueWithPagination(startTimeParsed, endTimeParsed, page, limit, nil, cursor)
+		if errors.Is(err, models.ErrInvalidCursor) {
+			params.fail("Invalid cursor: %v", err)
+			params.valid(w)
+			return
+		}
+	case includePrompts == "true":
+		sessionIDs, err = hs.DataService.GetSessionIDSWithPrompts(startTimeParsed, endTimeParsed)
+	default:
+		sessionIDs, err = hs.DataService.GetSessionIDSUnique(startTimeParsed, endTimeParsed)
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error fetching sessions: %v", err), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Query().Get(common.INCLUDE_COUNTS) == "true" {
+		ids := make([]string, len(sessionIDs))
+		for i, session := range sessionIDs {
+			ids[i] = session.ID
+		}
+		counts, err := hs.DataService.GetSessionSpanCounts(ids)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Error fetching session span counts: %v", err), http.StatusInternalServerError)
+			return
+		}
+		for i := range sessionIDs {
+			count := counts[sessionIDs[i].ID]
+			sessionIDs[i].SpanCount = &count.SpanCount
+			sessionIDs[i].ErrorCount = &count.ErrorCount
+		}
+	}
 
+	if !paginated {
+		total = len(sessionIDs)
+	}
 	response := models.SessionsResponse{
-		Data:  sessionIDs,
-		Total: len(sessionIDs),
+		Data:       sessionIDs,
+		NextCursor: nextCursor,
 	}
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	// Cursor pages skip the count, clients keep the total of the first page
+	if cursor == "" {
+		response.Total = &total
+	}
+	if err := writeJSON(w, r, http.StatusOK, response); err != nil {
 		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
 		return
 	}
@@ -126,7 +201,7 @@ func (hs *HttpServer) Sessions(w http.ResponseWriter, r *http.Request) {
 // @Produce      json
 // @Param        session_ids query string true "Comma-separated list of session IDs (max 50)" example("session_abc123,session_def456,session_ghi789")
 // @Success      200 {object} models.SessionSpansResponse "Map of session IDs to their traces with not found session information"
-// @Failure      400 {object} string "Bad request"
+// @Failure      400 {object} ErrorResponse "Bad request"
 // @Failure      500 {object} string "Internal server error"
 // @Router       /traces/sessions/spans [get]
 func (hs *HttpServer) SessionSpans(w http.ResponseWriter, r *http.Request) {
@@ -136,9 +211,9 @@ func (hs *HttpServer) SessionSpans(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get session_ids parameter
-	sessionIDsParam := r.URL.Query().Get("session_ids")
-	if sessionIDsParam == "" {
-		http.Error(w, "session_ids parameter is required", http.StatusBadRequest)
+	params := newQueryParams(r)
+	sessionIDsParam := params.required("session_ids")
+	if !params.valid(w) {
 		return
 	}
 
@@ -155,12 +230,12 @@ func (hs *HttpServer) SessionSpans(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if len(validSessionIDs) == 0 {
-		http.Error(w, "No valid session IDs provided", http.StatusBadRequest)
-		return
+		params.fail("No valid session IDs provided")
 	}
-
 	if len(validSessionIDs) > 50 {
-		http.Error(w, "Too many session IDs provided (maximum 50)", http.StatusBadRequest)
+		params.fail("Too many session IDs provided (maximum 50)")
+	}
+	if !params.valid(w) {
 		return
 	}
 
@@ -176,21 +251,139 @@ func (hs *HttpServer) SessionSpans(w http.ResponseWriter, r *http.Request) {
 		NotFoundSessionIds: notFoundSessionIds,
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	if err := json.NewEncoder(w).Encode(response); err != nil {
+	if err := writeJSON(w, r, http.StatusOK, response); err != nil {
 		http.Error(w, fmt.Sprintf("Error encoding response: %v", err), http.StatusInternalServerError)
 		return
 	}
 }
 
+// @Summary      Check which sessions have traces
+// @Description  Split a list of session IDs into the ones that have traces and the ones that do not. Allowed in read-only mode
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        request body models.SessionsExistRequest true "Session IDs to check (max 1000)" example({"session_ids": ["session_abc123", "session_def456"]})
+// @Success      200 {object} models.SessionsExistResponse "Present and missing session IDs"
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /traces/sessions/exists [post]
+func (hs *HttpServer) SessionsExist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	var request models.SessionsExistRequest
+	if err := hs.decodeJSON(r, &request); err != nil {
+		params.fail("Invalid request body: %v", err)
+	}
+
+	var validSessionIDs []string
+	for _, id := range request.SessionIDs {
+		trimmed := strings.TrimSpace(id)
+		if trimmed != "" {
+			validSessionIDs = append(validSessionIDs, trimmed)
+		}
+	}
+
+	if len(validSessionIDs) == 0 {
+		params.fail("No valid session IDs provided")
+	}
+	if len(validSessionIDs) > common.SESSIONS_EXIST_MAX_IDS {
+		params.fail("Too many session IDs provided (maximum %d)", common.SESSIONS_EXIST_MAX_IDS)
+	}
+	if !params.valid(w) {
+		return
+	}
+
+	present, missing, err := hs.DataService.CheckSessionsExist(validSessionIDs)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error checking sessions: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, models.SessionsExistResponse{Present: present, Missing: missing})
+}
+
+// @Summary      Get the conversation of a session
+// @Description  Get the gen_ai prompt and completion messages of a session in chronological order. Prompts repeating the conversation history are only listed once. Sessions without gen_ai attributes return an empty conversation
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        session_id path string true "Session ID" example("session_abc123")
+// @Success      200 {object} models.SessionConversation "Conversation messages" example({"session_id": "session_abc123", "messages": [{"role": "user", "content": "What is the weather?", "span_id": "span_1", "timestamp": "2023-06-25T15:30:00Z"}, {"role": "assistant", "content": "It is sunny.", "span_id": "span_1", "timestamp": "2023-06-25T15:30:00Z"}]})
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /sessions/{session_id}/conversation [get]
+func (hs *HttpServer) SessionConversation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	sessionID := params.pathVar(common.SESSION_ID, "Session ID")
+	if !params.valid(w) {
+		return
+	}
+
+	messages, err := hs.DataService.GetSessionConversation(sessionID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching conversation for session %s: %v", sessionID, err), http.StatusInternalServerError)
+		return
+	}
+	if messages == nil {
+		messages = []models.ConversationMessage{}
+	}
+
+	writeJSON(w, r, http.StatusOK, models.SessionConversation{SessionID: sessionID, Messages: messages})
+}
+
+// @Summary      Purge a session
+// @Description  Delete every metric of a session and the spans whose extracted session id equals it exactly, returning the number of rows targeted per table. Spans are never deleted by suffix: with the raw session id strategy, spans whose session.id only ends with the id are kept, although GET /traces/session/{session_id} matches by suffix and still returns them. Deletes run as ClickHouse mutations, so purged rows can stay visible for a short time. Every table is attempted even when one fails, and a 500 returns the counts with the failed tables, so the request can be retried. Rejected in read-only mode
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        session_id path string true "Session ID" example("session_abc123")
+// @Success      200 {object} models.PurgeResult "Rows targeted per table"
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} models.PurgeResult "Some tables were not purged"
+// @Failure      503 {object} SimpleMessage "Read-only mode"
+// @Router       /sessions/{session_id} [delete]
+func (hs *HttpServer) PurgeSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	sessionID := params.pathVar(common.SESSION_ID, "Session ID")
+	if !params.valid(w) {
+		return
+	}
+
+	result, err := hs.DataService.PurgeSession(sessionID)
+	if err != nil {
+		result.SessionID = sessionID
+		result.Error = fmt.Sprintf("Error purging session ID %s: %v", sessionID, err)
+		writeJSON(w, r, http.StatusInternalServerError, result)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, result)
+}
+
 // @Summary      Get traces by session ID
-// @Description  Get traces by session ID
+// @Description  Get traces by session ID. Send "Accept: application/x-ndjson" to stream one span per line instead of a JSON array
 // @Tags         APIs
 // @Accept       json
 // @Produce      json
+// @Produce      application/x-ndjson
 // @Param        session_id path string true "Session ID" example("session_abc123")
+// @Param        fields query string false "Comma-separated trace fields to return, all fields when omitted (see /traces/schema for the keys)" example("SpanName,Duration")
 // @Success      200 {array} Trace "List of traces for the session" example([{"trace_id": "trace_def456", "span_name": "ml_inference", "timestamp": "2023-06-25T15:30:00Z"}, {"trace_id": "trace_ghi789", "span_name": "data_processing", "timestamp": "2023-06-25T15:31:00Z"}])
-// @Failure      400 {object} string "Bad request"
+// @Failure      400 {object} ErrorResponse "Bad request"
 // @Failure      500 {object} string "Internal server error"
 // @Router       /traces/session/{session_id} [get]
 func (hs *HttpServer) Traces(w http.ResponseWriter, r *http.Request) {
@@ -199,10 +392,22 @@ func (hs *HttpServer) Traces(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vars := mux.Vars(r)
-	sessionID := vars[common.SESSION_ID]
-	if sessionID == "" {
-		http.Error(w, "Session ID is required", http.StatusBadRequest)
+	params := newQueryParams(r)
+	sessionID := params.pathVar(common.SESSION_ID, "Session ID")
+
+	var fields []string
+	if fieldsParam := r.URL.Query().Get(common.FIELDS); fieldsParam != "" {
+		var err error
+		if fields, err = models.ParseTraceFields(fieldsParam); err != nil {
+			params.fail("Invalid fields: %v", err)
+		}
+	}
+	if !params.valid(w) {
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), common.CONTENT_TYPE_NDJSON) {
+		hs.streamTraces(w, sessionID, fields)
 		return
 	}
 
@@ -212,8 +417,16 @@ func (hs *HttpServer) Traces(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(traces)
+	if len(fields) > 0 {
+		projected := make([]map[string]interface{}, len(traces))
+		for i, trace := range traces {
+			projected[i] = trace.Project(fields)
+		}
+		writeJSON(w, r, http.StatusOK, projected)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, traces)
 
 }
 
@@ -222,9 +435,10 @@ func (hs *HttpServer) Traces(w http.ResponseWriter, r *http.Request) {
 // @Tags         APIs
 // @Accept       json
 // @Produce      json
+// @Param        upsert query bool false "Replace stored metrics of the same session, span and metric key instead of appending, metrics without a span_id are keyed on the empty span"
 // @Param        metric body CreateMetric true "Metric to write" example({"span_id": "span_abc123", "trace_id": "trace_def456", "session_id": "session_ghi789", "metrics": {"accuracy": "0.95", "latency_ms": "120", "error_count": "3"}, "app_name": "ml-service", "app_id": "app-001"})
 // @Success      201 {object} Metric "Metric created successfully"
-// @Failure      400 {object} string "Bad request"
+// @Failure      400 {object} ErrorResponse "Bad request"
 // @Failure      500 {object} string "Internal server error"
 // @Router       /metrics/session [post]
 func (hs *HttpServer) WriteMetricsSession(w http.ResponseWriter, r *http.Request) {
@@ -241,9 +455,10 @@ func (hs *HttpServer) WriteMetricsSession(w http.ResponseWriter, r *http.Request
 // @Tags         APIs
 // @Accept       json
 // @Produce      json
+// @Param        upsert query bool false "Replace stored metrics of the same session, span and metric key instead of appending, span_id is required"
 // @Param        metric body CreateMetric true "Metric to write" example({"span_id": "span_xyz789", "trace_id": "trace_uvw123", "session_id": "session_rst456", "metrics": {"response_time": "200", "cache_hit": "true", "error_type": "timeout"}, "app_name": "api-gateway", "app_id": "app-002"})
 // @Success      201 {object} Metric "Metric created successfully"
-// @Failure      400 {object} string "Bad request"
+// @Failure      400 {object} ErrorResponse "Bad request"
 // @Failure      500 {object} string "Internal server error"
 // @Router       /metrics/span [post]
 func (hs *HttpServer) WriteMetricsSpan(w http.ResponseWriter, r *http.Request) {
@@ -261,8 +476,13 @@ func (hs *HttpServer) WriteMetricsSpan(w http.ResponseWriter, r *http.Request) {
 // @Accept       json
 // @Produce      json
 // @Param        session_id path string true "Session ID" example("session_abc123")
+// @Param        page query int false "Zero-based page number, used with limit" example(0)
+// @Param        limit query int false "Maximum number of metrics per page (all metrics when omitted)" example(50)
+// @Param        sort query string false "Sort order: timestamp_asc or timestamp_desc (default)" example("timestamp_desc")
+// @Param        scope query string false "Metric scope to read: session or span" example("session")
+// @Param        typed query bool false "Return numeric-looking string metric values as JSON numbers"
 // @Success      200 {array} Metric "List of metrics for the session" example([{"id": "metric_001", "span_id": "span_abc123", "trace_id": "trace_def456", "session_id": "session_abc123", "timestamp": "2023-06-25T15:30:00Z", "metrics": {"accuracy": "0.95", "latency_ms": "120"}, "app_name": "ml-service", "app_id": "app-001"}])
-// @Failure      400 {object} string "Bad request"
+// @Failure      400 {object} ErrorResponse "Bad request"
 // @Failure      500 {object} string "Internal server error"
 // @Router       /metrics/session/{session_id} [get]
 func (hs *HttpServer) GetMetricsSession(w http.ResponseWriter, r *http.Request) {
@@ -271,21 +491,24 @@ func (hs *HttpServer) GetMetricsSession(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	vars := mux.Vars(r)
-	sessionID := vars[common.SESSION_ID]
-	if sessionID == "" {
-		http.Error(w, "Session ID is required", http.StatusBadRequest)
+	params := newQueryParams(r)
+	sessionID := params.pathVar(common.SESSION_ID, "Session ID")
+	page, limit, sort := params.metricListOptions()
+	scope := params.metricScope(common.METRIC_SCOPE_SESSION)
+	if !params.valid(w) {
 		return
 	}
 
-	metrics, err := hs.DataService.GetMetricsBySessionIdAndScope(sessionID, common.METRIC_SCOPE_SESSION)
+	metrics, err := hs.DataService.GetMetricsBySessionIdAndScope(sessionID, scope, page, limit, sort)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error fetching metrics for session ID %s: %v", sessionID, err), http.StatusInternalServerError)
 		return
 	}
+	if r.URL.Query().Get(common.TYPED) == "true" {
+		typedMetrics(metrics)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(metrics)
+	writeJSON(w, r, http.StatusOK, metrics)
 }
 
 // @Summary      Get metrics by span ID
@@ -294,8 +517,13 @@ func (hs *HttpServer) GetMetricsSession(w http.ResponseWriter, r *http.Request)
 // @Accept       json
 // @Produce      json
 // @Param        span_id path string true "Span ID" example("span")
+// @Param        page query int false "Zero-based page number, used with limit" example(0)
+// @Param        limit query int false "Maximum number of metrics per page (all metrics when omitted)" example(50)
+// @Param        sort query string false "Sort order: timestamp_asc or timestamp_desc (default)" example("timestamp_desc")
+// @Param        scope query string false "Metric scope to read: session or span" example("session")
+// @Param        typed query bool false "Return numeric-looking string metric values as JSON numbers"
 // @Success      200 {array} Metric "List of metrics for the span" example([{"id": "metric_001", "span_id": "span_abc123", "trace_id": "trace_def456", "session_id": "session_abc123", "timestamp": "2023-06-25T15:30:00Z", "metrics": {"accuracy": "0.95", "latency_ms": "120"}, "app_name": "ml-service", "app_id": "app-001"}])
-// @Failure      400 {object} string "Bad request"
+// @Failure      400 {object} ErrorResponse "Bad request"
 // @Failure      500 {object} string "Internal server error"
 // @Router       /metrics/span/{span_id} [get]
 func (hs *HttpServer) GetMetricsSpan(w http.ResponseWriter, r *http.Request) {
@@ -304,21 +532,249 @@ func (hs *HttpServer) GetMetricsSpan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	vars := mux.Vars(r)
-	spanID := vars[common.SPAN_ID]
-	if spanID == "" {
-		http.Error(w, "Span ID is required", http.StatusBadRequest)
+	params := newQueryParams(r)
+	spanID := params.pathVar(common.SPAN_ID, "Span ID")
+	page, limit, sort := params.metricListOptions()
+	scope := params.metricScope(common.METRIC_SCOPE_SPAN)
+	if !params.valid(w) {
 		return
 	}
 
-	metrics, err := hs.DataService.GetMetricsBySpanIdAndScope(spanID, common.METRIC_SCOPE_SPAN)
+	metrics, err := hs.DataService.GetMetricsBySpanIdAndScope(spanID, scope, page, limit, sort)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error fetching metrics for span ID %s: %v", spanID, err), http.StatusInternalServerError)
 		return
 	}
+	if r.URL.Query().Get(common.TYPED) == "true" {
+		typedMetrics(metrics)
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(metrics)
+	writeJSON(w, r, http.StatusOK, metrics)
+}
+
+// @Summary      Roll up span metrics to a session
+// @Description  Aggregate one metric key over the span metrics of a session. Values must be numbers or numeric strings
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        session_id path string true "Session ID" example("session_abc123")
+// @Param        key query string true "Metric key to aggregate" example("latency_ms")
+// @Param        agg query string true "Aggregation: sum, avg, min or max" example("sum")
+// @Success      200 {object} models.MetricRollup "Aggregated value"
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      404 {object} string "No numeric values found"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /metrics/session/{session_id}/rollup [get]
+func (hs *HttpServer) MetricsSessionRollup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	sessionID := params.pathVar(common.SESSION_ID, "Session ID")
+	key := params.required(common.KEY)
+	agg := r.URL.Query().Get(common.AGG)
+	if !common.IsValidRollup(agg) {
+		params.fail("Invalid agg %q: must be sum, avg, min or max", agg)
+	}
+	if !params.valid(w) {
+		return
+	}
+
+	value, err := hs.DataService.RollupSpanMetricsToSession(sessionID, key, agg)
+	if errors.Is(err, models.ErrNoMetricValues) {
+		http.Error(w, fmt.Sprintf("No numeric values for metric %s in session ID %s", key, sessionID), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error rolling up metrics for session ID %s: %v", sessionID, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, models.MetricRollup{SessionID: sessionID, Key: key, Agg: agg, Value: value})
+}
+
+// @Summary      Compare the metrics of two sessions
+// @Description  Get the latest value of every metric key of two sessions side by side, with the delta (session_b - session_a) when both values are numeric. Keys present in only one session have a null value for the other one
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        session_a query string true "First session ID" example("session_abc123")
+// @Param        session_b query string true "Second session ID" example("session_def456")
+// @Param        scope query string false "Metric scope to read: session or span" example("session")
+// @Success      200 {object} models.MetricComparisonResponse "Metrics of both sessions by key" example({"session_a": "session_abc123", "session_b": "session_def456", "scope": "session", "metrics": {"latency_ms": {"session_a": "120", "session_b": "100", "delta": -20}, "accuracy": {"session_a": "0.95", "session_b": null}}})
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /metrics/compare [get]
+func (hs *HttpServer) MetricsCompare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	sessionA := r.URL.Query().Get(common.SESSION_A)
+	sessionB := r.URL.Query().Get(common.SESSION_B)
+	if sessionA == "" || sessionB == "" {
+		params.fail("session_a and session_b parameters are required")
+	}
+	scope := params.metricScope(common.METRIC_SCOPE_SESSION)
+	if !params.valid(w) {
+		return
+	}
+
+	metricsA, err := hs.DataService.GetMetricsBySessionIdAndScope(sessionA, scope, 0, 0, common.SORT_TIMESTAMP_DESC)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching metrics for session ID %s: %v", sessionA, err), http.StatusInternalServerError)
+		return
+	}
+	metricsB, err := hs.DataService.GetMetricsBySessionIdAndScope(sessionB, scope, 0, 0, common.SORT_TIMESTAMP_DESC)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching metrics for session ID %s: %v", sessionB, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, models.MetricComparisonResponse{
+		SessionA: sessionA,
+		SessionB: sessionB,
+		Scope:    scope,
+		Metrics:  models.CompareMetrics(metricsA, metricsB),
+	})
+}
+
+// @Summary      List applications with metrics
+// @Description  Get the distinct app name and app id pairs that have written metrics
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        start_time query string false "Start time in ISO 8601 format, normalized to UTC" example("2023-06-25T15:04:05Z")
+// @Param        end_time query string false "End time in ISO 8601 format, normalized to UTC" example("2023-06-25T18:04:05Z")
+// @Success      200 {array} models.AppInfo "List of applications" example([{"app_name": "ml-service", "app_id": "app-001"}])
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /metrics/apps [get]
+func (hs *HttpServer) MetricApps(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	startTime := params.optionalTime(common.START_TIME)
+	endTime := params.optionalTime(common.END_TIME)
+	if !params.valid(w) {
+		return
+	}
+
+	apps, err := hs.DataService.GetDistinctMetricApps(startTime, endTime)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching metric apps: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, apps)
+}
+
+// @Summary      List the spans of a session that have metrics
+// @Description  Get the span ids of a session that have at least one metric, with the number of metrics per span
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        session_id query string true "Session ID" example("session_abc123")
+// @Param        scope query string false "Metric scope to read: session or span (default span)" example("span")
+// @Success      200 {array} models.SpanMetricCount "Spans with metrics" example([{"span_id": "span_abc123", "metric_count": 2}])
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /metrics/spans [get]
+func (hs *HttpServer) MetricSpans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	sessionID := params.required(common.SESSION_ID)
+	scope := params.metricScope(common.METRIC_SCOPE_SPAN)
+	if !params.valid(w) {
+		return
+	}
+
+	spans, err := hs.DataService.GetSpansWithMetrics(sessionID, scope)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching spans with metrics for session ID %s: %v", sessionID, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, spans)
+}
+
+// @Summary      Get the span events of a session
+// @Description  Get the events of every span of a session as a flat list ordered by timestamp
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        session_id path string true "Session ID" example("session_abc123")
+// @Param        event_name query string false "Only return events with this name" example("tool_call")
+// @Success      200 {array} models.SpanEvent "Span events" example([{"trace_id": "trace_def456", "span_id": "span_abc123", "span_name": "agent", "timestamp": "2023-06-25T15:30:00Z", "name": "tool_call", "attributes": {"tool.name": "search"}}])
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /traces/session/{session_id}/events [get]
+func (hs *HttpServer) SessionEvents(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	sessionID := params.pathVar(common.SESSION_ID, "Session ID")
+	if !params.valid(w) {
+		return
+	}
+
+	var eventName *string
+	if eventNameParam := r.URL.Query().Get(common.EVENT_NAME); eventNameParam != "" {
+		eventName = &eventNameParam
+	}
+
+	events, err := hs.DataService.GetSessionEvents(sessionID, eventName)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching events for session ID %s: %v", sessionID, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, events)
+}
+
+// @Summary      Get the call graph edges of a session
+// @Description  Get the directed transitions between consecutive spans of a session with their occurrence counts. The first span is reached from START and the last one leads to END
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        session_id path string true "Session ID" example("tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8")
+// @Success      200 {array} models.CallGraphEdge "Call graph edges" example([{"from_span": "START", "to_span": "agent", "count": 1}, {"from_span": "agent", "to_span": "llm_call", "count": 3}])
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /traces/session/{session_id}/callgraph/edges [get]
+func (hs *HttpServer) CallGraphEdges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	sessionID := params.pathVar(common.SESSION_ID, "Session ID")
+	if !params.valid(w) {
+		return
+	}
+
+	edges, err := hs.DataService.GetCallGraphEdges(sessionID)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching call graph edges for session ID %s: %v", sessionID, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, edges)
 }
 
 // @Summary      Get a single span by session ID and span ID
@@ -329,7 +785,7 @@ func (hs *HttpServer) GetMetricsSpan(w http.ResponseWriter, r *http.Request) {
 // @Param        session_id path string true "Session ID" example("tau2-airline_78e610a0-b3f3-4feb-93bd-ea314b83feb8")
 // @Param        span_id path string true "Span ID" example("f125e574-1e9e-40db-8720-82a62ff38464")
 // @Success      200 {object} Trace "The span data"
-// @Failure      400 {object} string "Bad request"
+// @Failure      400 {object} ErrorResponse "Bad request"
 // @Failure      404 {object} string "Span not found"
 // @Failure      500 {object} string "Internal server error"
 // @Router       /traces/session/{session_id}/span/{span_id} [get]
@@ -339,16 +795,10 @@ func (hs *HttpServer) SpanBySessionAndSpanID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	vars := mux.Vars(r)
-	sessionID := vars[common.SESSION_ID]
-	spanID := vars[common.SPAN_ID]
-
-	if sessionID == "" {
-		http.Error(w, "Session ID is required", http.StatusBadRequest)
-		return
-	}
-	if spanID == "" {
-		http.Error(w, "Span ID is required", http.StatusBadRequest)
+	params := newQueryParams(r)
+	sessionID := params.pathVar(common.SESSION_ID, "Session ID")
+	spanID := params.pathVar(common.SPAN_ID, "Span ID")
+	if !params.valid(w) {
 		return
 	}
 
@@ -362,8 +812,256 @@ func (hs *HttpServer) SpanBySessionAndSpanID(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(span)
+	writeJSON(w, r, http.StatusOK, span)
+}
+
+// @Summary      Get trace statistics
+// @Description  Get the span count, error span count, distinct services and total duration of a trace
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        trace_id path string true "Trace ID" example("4bf92f3577b34da6a3ce929d0e0e4736")
+// @Success      200 {object} models.TraceStats "Trace statistics" example({"trace_id": "4bf92f3577b34da6a3ce929d0e0e4736", "span_count": 12, "error_span_count": 1, "services": ["api-gateway", "ml-service"], "total_duration_ms": 1532.4})
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      404 {object} string "Trace not found"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /traces/trace/{trace_id}/stats [get]
+func (hs *HttpServer) TraceStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	traceID := params.pathVar(common.TRACE_ID, "Trace ID")
+	if !params.valid(w) {
+		return
+	}
+
+	stats, err := hs.DataService.GetTraceStats(traceID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, fmt.Sprintf("Trace not found: %s", traceID), http.StatusNotFound)
+		} else {
+			http.Error(w, fmt.Sprintf("Error fetching stats for trace %s: %v", traceID, err), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, stats)
+}
+
+// @Summary      Get the trace schema
+// @Description  Get the columns of the otel_traces table with their ClickHouse type and the key of the matching field in trace responses
+// @Tags         APIs
+// @Produce      json
+// @Success      200 {array} models.TraceField "Trace fields" example([{"field": "TraceId", "column": "TraceId", "json_key": "TraceId", "type": "String"}])
+// @Router       /traces/schema [get]
+func (hs *HttpServer) TraceSchema(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, models.OtelTracesSchema())
+}
+
+// @Summary      Get trace attribute keys
+// @Description  Get the distinct span attribute keys seen in a time window, optionally for a single service
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        start_time query string true "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T15:04:05Z")
+// @Param        end_time query string true "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T18:04:05Z")
+// @Param        service_name query string false "Service name" example("ml-service")
+// @Param        limit query int false "Maximum number of keys to return (default 100, max 1000)" example(100)
+// @Success      200 {array} string "List of attribute keys"
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /traces/attribute-keys [get]
+func (hs *HttpServer) TraceAttributeKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	startTimeParsed := params.requiredTime(common.START_TIME)
+	endTimeParsed := params.requiredTime(common.END_TIME)
+
+	limit := min(params.limit(common.ATTRIBUTE_KEYS_DEFAULT_LIMIT), common.ATTRIBUTE_KEYS_MAX_LIMIT)
+	if !params.valid(w) {
+		return
+	}
+
+	var serviceName *string
+	if serviceNameParam := r.URL.Query().Get(common.SERVICE_NAME); serviceNameParam != "" {
+		serviceName = &serviceNameParam
+	}
+
+	keys, err := hs.DataService.GetTraceAttributeKeys(serviceName, startTimeParsed, endTimeParsed, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching trace attribute keys: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, keys)
+}
+
+// @Summary      Get token usage per session
+// @Description  Get the total llm.usage.total_tokens of every session in the time window, largest first
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        start_time query string true "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T15:04:05Z")
+// @Param        end_time query string true "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T18:04:05Z")
+// @Param        page query int false "Page number starting at 0 (default 0)"
+// @Param        limit query int false "Page size (default 100, max 1000)"
+// @Success      200 {object} models.SessionTokenUsageResponse "Token usage per session" example({"data": [{"session_id": "session_abc123", "total_tokens": 1520}], "total": 1})
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /insights/tokens/sessions [get]
+func (hs *HttpServer) SessionTokenUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	startTimeParsed, endTimeParsed := params.timeWindow()
+	page, limit := params.sessionPage()
+	if !params.valid(w) {
+		return
+	}
+
+	usage, total, err := hs.DataService.GetTokenUsagePerSession(startTimeParsed, endTimeParsed, page, limit)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching token usage per session: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, models.SessionTokenUsageResponse{Data: usage, Total: total})
+}
+
+// @Summary      Get the graph determinism of an app
+// @Description  Compare the execution paths, the time-ordered span names, of the sessions of an app. The score is the fraction of sessions following the most frequent path
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        app_name query string true "App name, matched against the span service name" example("ml-service")
+// @Param        start_time query string true "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T15:04:05Z")
+// @Param        end_time query string true "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T18:04:05Z")
+// @Success      200 {object} models.GraphDeterminism "Graph determinism" example({"app_name": "ml-service", "session_count": 4, "unique_path_count": 2, "dominant_path": ["agent", "llm_call", "tool_call"], "dominant_path_count": 3, "score": 0.75})
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /insights/graph-determinism [get]
+func (hs *HttpServer) GraphDeterminism(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	appName := params.required(common.APP_NAME)
+	startTimeParsed, endTimeParsed := params.timeWindow()
+	if !params.valid(w) {
+		return
+	}
+
+	determinism, err := hs.DataService.GetGraphDeterminism(appName, startTimeParsed, endTimeParsed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error computing graph determinism for app %s: %v", appName, err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, determinism)
+}
+
+// @Summary      Get the metric matrix of apps by metric key
+// @Description  Get the average of each requested metric key per app. Numeric strings are averaged as numbers, and apps without a numeric value for a key get null
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        keys query string true "Comma-separated metric keys (max 50)" example("accuracy,latency_ms")
+// @Param        scope query string false "Metric scope to read: session or span (default session)" example("session")
+// @Success      200 {object} models.MetricMatrix "Average per app and metric key" example({"keys": ["accuracy", "latency_ms"], "apps": [{"app_name": "ml-service", "values": {"accuracy": 0.95, "latency_ms": null}}]})
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /insights/metrics/matrix [get]
+func (hs *HttpServer) MetricMatrix(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var keys []string
+	seen := make(map[string]bool)
+	for _, key := range strings.Split(r.URL.Query().Get(common.KEYS), ",") {
+		key = strings.TrimSpace(key)
+		if key != "" && !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	params := newQueryParams(r)
+	if len(keys) == 0 {
+		params.fail("%s parameter is required", common.KEYS)
+	}
+	if len(keys) > common.METRIC_MATRIX_MAX_KEYS {
+		params.fail("Too many keys provided (maximum %d)", common.METRIC_MATRIX_MAX_KEYS)
+	}
+	scope := params.metricScope(common.METRIC_SCOPE_SESSION)
+	if !params.valid(w) {
+		return
+	}
+
+	matrix, err := hs.DataService.GetMetricMatrix(keys, scope)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching metric matrix: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, matrix)
+}
+
+// @Summary      Get span duration statistics
+// @Description  Get count, average, max and p95 span duration in milliseconds grouped by span name, optionally for a single service
+// @Tags         APIs
+// @Accept       json
+// @Produce      json
+// @Param        start_time query string true "Start time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T15:04:05Z")
+// @Param        end_time query string true "End time in ISO 8601 format, normalized to UTC (e.g. 2023-06-25T15:04:05Z)" example("2023-06-25T18:04:05Z")
+// @Param        service_name query string false "Service name" example("ml-service")
+// @Success      200 {array} models.SpanDurationStat "Duration statistics per span name"
+// @Failure      400 {object} ErrorResponse "Bad request"
+// @Failure      500 {object} string "Internal server error"
+// @Router       /insights/spans/durations [get]
+func (hs *HttpServer) SpanDurations(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := newQueryParams(r)
+	startTimeParsed := params.requiredTime(common.START_TIME)
+	endTimeParsed := params.requiredTime(common.END_TIME)
+	if !params.valid(w) {
+		return
+	}
+
+	var serviceName *string
+	if serviceNameParam := r.URL.Query().Get(common.SERVICE_NAME); serviceNameParam != "" {
+		serviceName = &serviceNameParam
+	}
+
+	stats, err := hs.DataService.GetSpanDurationStats(serviceName, startTimeParsed, endTimeParsed)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching span duration stats: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, stats)
 }
 
 func KeepAlive(w http.ResponseWriter, r *http.Request) {
@@ -372,8 +1070,64 @@ func KeepAlive(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	response := SimpleMessage{Message: "I'm alive!"}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, r, http.StatusOK, response)
+}
+
+// @Summary      Check readiness
+// @Description  Ping every backing service and report the status of each component. Returns 503 when a required component is down
+// @Tags         APIs
+// @Produce      json
+// @Success      200 {object} ReadyResponse "Every required component is up" example({"status": "ready", "components": {"data": {"status": "up", "required": true}}})
+// @Failure      503 {object} ReadyResponse "A required component is down"
+// @Router       /ready [get]
+func (hs *HttpServer) Ready(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	response := ReadyResponse{Status: "ready", Components: map[string]ComponentStatus{}}
+	status := http.StatusOK
+
+	data := ComponentStatus{Status: "up", Required: true}
+	if err := hs.DataService.Ping(); err != nil {
+		logger.Zap.Error("Data service is not reachable", logger.Error(err))
+		data.Status = "down"
+		data.Error = err.Error()
+	}
+	response.Components["data"] = data
+
+	for _, component := range response.Components {
+		if component.Required && component.Status != "up" {
+			response.Status = "not_ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	writeJSON(w, r, status, response)
+}
+
+// @Summary      Get server info
+// @Description  Get the active server configuration, such as the session id extraction strategy
+// @Tags         APIs
+// @Produce      json
+// @Success      200 {object} InfoResponse "Server configuration"
+// @Router       /info [get]
+func (hs *HttpServer) Info(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	strategy := hs.SessionIDStrategy
+	if strategy == "" {
+		strategy = common.SESSION_ID_STRATEGY_RAW
+	}
+	response := InfoResponse{
+		SessionIDStrategy: strategy,
+		SessionIDRegex:    hs.SessionIDRegex,
+	}
+	writeJSON(w, r, http.StatusOK, response)
 }
 
 func PrometeusMetrics(w http.ResponseWriter, r *http.Request) {
@@ -384,11 +1138,249 @@ func PrometeusMetrics(w http.ResponseWriter, r *http.Request) {
 	promhttp.Handler().ServeHTTP(w, r)
 }
 
+// streamTraces writes the session spans as NDJSON, flushing after each span.
+// When fields is set only those fields of each span are written
+func (hs *HttpServer) streamTraces(w http.ResponseWriter, sessionID string, fields []string) {
+	encoder := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	written := false
+
+	err := hs.DataService.StreamTracesBySessionID(sessionID, func(trace models.OtelTraces) error {
+		if !written {
+			w.Header().Set("Content-Type", common.CONTENT_TYPE_NDJSON)
+			written = true
+		}
+		var line interface{} = trace
+		if len(fields) > 0 {
+			line = trace.Project(fields)
+		}
+		if err := encoder.Encode(line); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	})
+	if err != nil {
+		if !written {
+			http.Error(w, fmt.Sprintf("Error fetching traces for session ID %s: %v", sessionID, err), http.StatusInternalServerError)
+			return
+		}
+		// Headers are already sent, the truncated stream is all the client gets
+		logger.Zap.Error("Error streaming traces", logger.Error(err), logger.String("sessionID", sessionID))
+		return
+	}
+
+	if !written {
+		w.Header().Set("Content-Type", common.CONTENT_TYPE_NDJSON)
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// decodeJSON decodes the request body into v. In strict mode unknown fields,
+// such as a misspelled field name, are rejected instead of being ignored
+func (hs *HttpServer) decodeJSON(r *http.Request, v interface{}) error {
+	decoder := json.NewDecoder(r.Body)
+	if hs.StrictJSON {
+		decoder.DisallowUnknownFields()
+	}
+	return decoder.Decode(v)
+}
+
+// typedMetrics coerces the numeric-looking string values of every metric into JSON numbers
+func typedMetrics(metrics []models.Metric) {
+	for i := range metrics {
+		if metrics[i].Metrics != nil {
+			typed := metrics[i].Metrics.Typed()
+			metrics[i].Metrics = &typed
+		}
+	}
+}
+
+// ErrorResponse is the body of a request rejected by validation
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// queryParams validates the query parameters, path variables and body fields
+// of a request. Only the first failed check is kept, later checks are skipped
+type queryParams struct {
+	r   *http.Request
+	err error
+}
+
+func newQueryParams(r *http.Request) *queryParams {
+	return &queryParams{r: r}
+}
+
+// required returns the parameter, failing when it is missing or empty
+func (q *queryParams) required(name string) string {
+	if q.err != nil {
+		return ""
+	}
+	value := q.r.URL.Query().Get(name)
+	if value == "" {
+		q.err = fmt.Errorf("%s parameter is required", name)
+	}
+	return value
+}
+
+// requiredTime returns the parameter parsed as an ISO 8601 time in UTC
+func (q *queryParams) requiredTime(name string) time.Time {
+	value := q.required(name)
+	if q.err != nil {
+		return time.Time{}
+	}
+	return q.parseTime(name, value)
+}
+
+// optionalTime returns the parameter parsed as an ISO 8601 time in UTC, or
+// nil when it is not set
+func (q *queryParams) optionalTime(name string) *time.Time {
+	value := q.r.URL.Query().Get(name)
+	if q.err != nil || value == "" {
+		return nil
+	}
+	parsed := q.parseTime(name, value)
+	return &parsed
+}
+
+func (q *queryParams) parseTime(name, value string) time.Time {
+	parsed, err := common.ParseTime(value)
+	if err != nil {
+		q.err = fmt.Errorf("Invalid %s: %v", name, err)
+	}
+	return parsed
+}
+
+// timeWindow returns the required start_time and end_time, failing when the
+// window ends before it starts
+func (q *queryParams) timeWindow() (startTime, endTime time.Time) {
+	startTime = q.requiredTime(common.START_TIME)
+	endTime = q.requiredTime(common.END_TIME)
+	if q.err == nil && endTime.Before(startTime) {
+		q.err = errors.New("Invalid time window: end_time is before start_time")
+	}
+	return startTime, endTime
+}
+
+// check keeps err as the failure unless an earlier check already failed
+func (q *queryParams) check(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// fail records a failure built from format unless an earlier check already
+// failed
+func (q *queryParams) fail(format string, args ...interface{}) {
+	q.check(fmt.Errorf(format, args...))
+}
+
+// pathVar returns the path variable, failing with "<label> is required" when
+// it is empty
+func (q *queryParams) pathVar(name, label string) string {
+	value := mux.Vars(q.r)[name]
+	if value == "" {
+		q.fail("%s is required", label)
+	}
+	return value
+}
+
+// page returns the zero-based page parameter, 0 when it is not set
+func (q *queryParams) page() int {
+	value := q.r.URL.Query().Get(common.PAGE)
+	if q.err != nil || value == "" {
+		return 0
+	}
+	page, err := strconv.Atoi(value)
+	if err != nil || page < 0 {
+		q.err = errors.New("Invalid page: must be a non-negative integer")
+	}
+	return page
+}
+
+// limit returns the limit parameter, or fallback when it is not set
+func (q *queryParams) limit(fallback int) int {
+	value := q.r.URL.Query().Get(common.LIMIT)
+	if q.err != nil || value == "" {
+		return fallback
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit <= 0 {
+		q.err = errors.New("Invalid limit: must be a positive integer")
+	}
+	return limit
+}
+
+// metricListOptions returns the page, limit and sort of the metric list
+// endpoints, all metrics newest first by default
+func (q *queryParams) metricListOptions() (page, limit int, sort string) {
+	page = q.page()
+	limit = q.limit(0)
+
+	sort = q.r.URL.Query().Get(common.SORT)
+	switch sort {
+	case "":
+		sort = common.SORT_TIMESTAMP_DESC
+	case common.SORT_TIMESTAMP_ASC, common.SORT_TIMESTAMP_DESC:
+	default:
+		q.fail("Invalid sort: must be %s or %s", common.SORT_TIMESTAMP_ASC, common.SORT_TIMESTAMP_DESC)
+	}
+	return page, limit, sort
+}
+
+// sessionPage returns the page and limit of the paginated per-session lists,
+// capping the limit at SESSIONS_MAX_LIMIT
+func (q *queryParams) sessionPage() (page, limit int) {
+	page = q.page()
+	limit = min(q.limit(common.SESSIONS_DEFAULT_LIMIT), common.SESSIONS_MAX_LIMIT)
+	return page, limit
+}
+
+// metricScope returns the scope parameter, or fallback when it is not set,
+// rejecting unknown scopes instead of returning no metrics
+func (q *queryParams) metricScope(fallback string) string {
+	scope := q.r.URL.Query().Get(common.SCOPE)
+	if scope == "" {
+		return fallback
+	}
+	if !common.IsValidScope(scope) {
+		q.fail("Invalid scope %q: must be %s or %s", scope, common.METRIC_SCOPE_SESSION, common.METRIC_SCOPE_SPAN)
+	}
+	return scope
+}
+
+// valid writes a 400 ErrorResponse when a check failed and reports whether
+// every check passed
+func (q *queryParams) valid(w http.ResponseWriter) bool {
+	if q.err == nil {
+		return true
+	}
+	writeJSON(w, q.r, http.StatusBadRequest, ErrorResponse{Error: q.err.Error()})
+	return false
+}
+
 func (hs *HttpServer) saveMetrics(w http.ResponseWriter, r *http.Request, metricScope string) {
 
+	params := newQueryParams(r)
+	upsert := false
+	if upsertParam := r.URL.Query().Get(common.UPSERT); upsertParam != "" {
+		parsed, err := strconv.ParseBool(upsertParam)
+		if err != nil {
+			params.fail("Invalid upsert: %q is not a boolean", upsertParam)
+		}
+		upsert = parsed
+	}
+	if !params.valid(w) {
+		return
+	}
+
 	var metricRequest models.MetricCreateRequest
-	if err := json.NewDecoder(r.Body).Decode(&metricRequest); err != nil {
-		http.Error(w, fmt.Sprintf("Error decoding request body: %v", err), http.StatusBadRequest)
+	if err := hs.decodeJSON(r, &metricRequest); err != nil {
+		params.fail("Error decoding request body: %v", err)
+		params.valid(w)
 		return
 	}
 
@@ -396,7 +1388,23 @@ func (hs *HttpServer) saveMetrics(w http.ResponseWriter, r *http.Request, metric
 	metric := metricRequest.ToMetric()
 	metric.Scope = &metricScope
 
-	createdMetric, err := hs.DataService.AddMetric(*metric)
+	params.check(hs.applyMetricKeyAllowlist(metric))
+	if !params.valid(w) {
+		return
+	}
+
+	var createdMetric models.Metric
+	var err error
+	if upsert {
+		createdMetric, err = hs.DataService.UpsertMetric(*metric)
+	} else {
+		createdMetric, err = hs.DataService.AddMetric(*metric)
+	}
+	if errors.Is(err, models.ErrIncompleteUpsert) {
+		params.fail("Error writing metric: %v", err)
+		params.valid(w)
+		return
+	}
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error writing metric: %v", err), http.StatusInternalServerError)
 		return
@@ -404,9 +1412,50 @@ func (hs *HttpServer) saveMetrics(w http.ResponseWriter, r *http.Request, metric
 
 	// Return the created metric with generated ID and timestamp
 	response := createdMetric.ToResponse()
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(response)
+	writeJSON(w, r, http.StatusCreated, response)
+}
+
+// applyMetricKeyAllowlist checks the metric keys against the allowlist of the
+// metric app. Disallowed keys are rejected, or dropped with a warning in drop
+// mode as long as at least one allowed key remains
+func (hs *HttpServer) applyMetricKeyAllowlist(metric *models.Metric) error {
+	if metric.AppName == nil || metric.Metrics == nil {
+		return nil
+	}
+	allowed, ok := hs.MetricKeyAllowlist[*metric.AppName]
+	if !ok {
+		return nil
+	}
+
+	keys, err := metric.Metrics.Keys()
+	if err != nil {
+		return fmt.Errorf("Invalid metrics: %v", err)
+	}
+
+	var disallowed []string
+	for _, key := range keys {
+		if !slices.Contains(allowed, key) {
+			disallowed = append(disallowed, key)
+		}
+	}
+	if len(disallowed) == 0 {
+		return nil
+	}
+
+	if hs.MetricKeyAllowlistMode == common.METRIC_KEY_ALLOWLIST_MODE_DROP && len(disallowed) < len(keys) {
+		filtered, err := metric.Metrics.WithoutKeys(disallowed)
+		if err != nil {
+			return fmt.Errorf("Invalid metrics: %v", err)
+		}
+		logger.Zap.Warn("Dropping metric keys not allowed for app",
+			logger.String("appName", *metric.AppName),
+			logger.Strings("keys", disallowed),
+		)
+		metric.Metrics = &filtered
+		return nil
+	}
+
+	return fmt.Errorf("Metric keys not allowed for app %s: %s", *metric.AppName, strings.Join(disallowed, ", "))
 }
 
 func createNewCounterVec(metricName string, metricHelp string) prometheus.Counter {
@@ -449,6 +1498,46 @@ func (hs *HttpServer) logMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// writeJSON writes v as the JSON response with the given status code. The
+// output is indented when the request asks for pretty=true, compact otherwise,
+// and successful responses are wrapped in a ResponseEnvelope when the request
+// sends X-Response-Envelope: true
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) error {
+	if status >= 200 && status < 300 && r.Header.Get(common.RESPONSE_ENVELOPE_HEADER) == "true" {
+		v = ResponseEnvelope{Data: v}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	if r.URL.Query().Get(common.PRETTY) == "true" {
+		encoder.SetIndent("", "  ")
+	}
+	return encoder.Encode(v)
+}
+
+// readOnlyAllowedPaths lists the POST endpoints that only read data
+var readOnlyAllowedPaths = map[string]bool{
+	"/traces/sessions/exists": true,
+}
+
+// readOnlyMiddleware rejects every write request with 503 when the server runs in read-only mode
+func (hs *HttpServer) readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hs.ReadOnly && !readOnlyAllowedPaths[r.URL.Path] {
+			switch r.Method {
+			case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+				logger.Zap.Info("Write rejected in read-only mode",
+					logger.String("Method", r.Method),
+					logger.String("Path", r.URL.Path),
+				)
+				writeJSON(w, r, http.StatusServiceUnavailable, SimpleMessage{Message: "The API is in read-only mode, write operations are disabled"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (hs *HttpServer) Run(ctx context.Context, wg *sync.WaitGroup) error {
 	defer wg.Done()
 
@@ -493,7 +1582,10 @@ func (hs *HttpServer) startServer() {
 		hs.keepAliveMetric = createNewCounterVec("keep_alive_request", "Keep Alive Requeste, it has to be always 1")
 		mux := mux.NewRouter()
 		mux.Use(hs.logMiddleware)
+		mux.Use(hs.readOnlyMiddleware)
 		mux.HandleFunc("/keepAlive", KeepAlive).Methods(http.MethodGet)
+		mux.HandleFunc("/info", hs.Info).Methods(http.MethodGet)
+		mux.HandleFunc("/ready", hs.Ready).Methods(http.MethodGet)
 
 		mux.HandleFunc(
 			"/metrics",
@@ -501,24 +1593,42 @@ func (hs *HttpServer) startServer() {
 		).Methods(http.MethodGet)
 
 		mux.HandleFunc("/traces/sessions/spans", hs.SessionSpans).Methods(http.MethodGet)
+		mux.HandleFunc("/traces/sessions/exists", hs.SessionsExist).Methods(http.MethodPost)
+		mux.HandleFunc("/traces/attribute-keys", hs.TraceAttributeKeys).Methods(http.MethodGet)
+		mux.HandleFunc("/traces/schema", hs.TraceSchema).Methods(http.MethodGet)
 
 		mux.HandleFunc(
 			"/traces/sessions",
 			hs.Sessions,
 		).Methods(http.MethodGet)
 
+		mux.HandleFunc("/insights/spans/durations", hs.SpanDurations).Methods(http.MethodGet)
+		mux.HandleFunc("/insights/tokens/sessions", hs.SessionTokenUsage).Methods(http.MethodGet)
+		mux.HandleFunc("/insights/graph-determinism", hs.GraphDeterminism).Methods(http.MethodGet)
+		mux.HandleFunc("/insights/metrics/matrix", hs.MetricMatrix).Methods(http.MethodGet)
+
 		mux.HandleFunc("/metrics/session", hs.WriteMetricsSession).Methods(http.MethodPost)
 		mux.HandleFunc("/metrics/span", hs.WriteMetricsSpan).Methods(http.MethodPost)
 
+		mux.HandleFunc("/metrics/apps", hs.MetricApps).Methods(http.MethodGet)
+		mux.HandleFunc("/metrics/compare", hs.MetricsCompare).Methods(http.MethodGet)
+		mux.HandleFunc("/metrics/spans", hs.MetricSpans).Methods(http.MethodGet)
 		mux.HandleFunc("/metrics/session/{session_id}", hs.GetMetricsSession).Methods(http.MethodGet)
+		mux.HandleFunc("/metrics/session/{session_id}/rollup", hs.MetricsSessionRollup).Methods(http.MethodGet)
 		mux.HandleFunc("/metrics/span/{span_id}", hs.GetMetricsSpan).Methods(http.MethodGet)
 
+		mux.HandleFunc("/sessions/{session_id}", hs.PurgeSession).Methods(http.MethodDelete)
+		mux.HandleFunc("/sessions/{session_id}/conversation", hs.SessionConversation).Methods(http.MethodGet)
 		mux.HandleFunc("/traces/session/{session_id}/span/{span_id}", hs.SpanBySessionAndSpanID).Methods(http.MethodGet)
+		mux.HandleFunc("/traces/session/{session_id}/callgraph/edges", hs.CallGraphEdges).Methods(http.MethodGet)
+		mux.HandleFunc("/traces/session/{session_id}/events", hs.SessionEvents).Methods(http.MethodGet)
+		mux.HandleFunc("/traces/trace/{trace_id}/stats", hs.TraceStats).Methods(http.MethodGet)
 		mux.HandleFunc("/traces/session/{session_id}", hs.Traces)
 		mux.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
 		logger.Zap.Info("Server is running on port", logger.Int("port", hs.Port))
 		c := cors.New(cors.Options{
 			AllowedOrigins:   []string{"http://localhost:8080"},
+			AllowedHeaders:   []string{"Accept", "Content-Type", "X-Requested-With", common.RESPONSE_ENVELOPE_HEADER},
 			AllowCredentials: true,
 		})
 		hs.httpServer = &http.Server{
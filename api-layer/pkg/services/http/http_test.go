@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -28,6 +29,11 @@ type MockDataService struct {
 	mock.Mock
 }
 
+func (m *MockDataService) Ping() error {
+	args := m.Called()
+	return args.Error(0)
+}
+
 func (m *MockDataService) GetSessionIDSUnique(startTime, endTime time.Time) ([]models.SessionUniqueID, error) {
 	args := m.Called(startTime, endTime)
 	return args.Get(0).([]models.SessionUniqueID), args.Error(1)
@@ -38,26 +44,86 @@ func (m *MockDataService) AddMetric(metric models.Metric) (models.Metric, error)
 	return args.Get(0).(models.Metric), args.Error(1)
 }
 
-func (m *MockDataService) GetMetricsBySessionIdAndScope(sessionID string, scope string) ([]models.Metric, error) {
-	args := m.Called(sessionID, scope)
+func (m *MockDataService) UpsertMetric(metric models.Metric) (models.Metric, error) {
+	args := m.Called(metric)
+	return args.Get(0).(models.Metric), args.Error(1)
+}
+
+func (m *MockDataService) GetMetricsBySessionIdAndScope(sessionID string, scope string, page, limit int, sort string) ([]models.Metric, error) {
+	args := m.Called(sessionID, scope, page, limit, sort)
 	return args.Get(0).([]models.Metric), args.Error(1)
 }
 
-func (m *MockDataService) GetMetricsBySpanIdAndScope(spanID string, scope string) ([]models.Metric, error) {
-	args := m.Called(spanID, scope)
+func (m *MockDataService) GetMetricsBySpanIdAndScope(spanID string, scope string, page, limit int, sort string) ([]models.Metric, error) {
+	args := m.Called(spanID, scope, page, limit, sort)
 	return args.Get(0).([]models.Metric), args.Error(1)
 }
 
+func (m *MockDataService) RollupSpanMetricsToSession(sessionID, metricKey, agg string) (float64, error) {
+	args := m.Called(sessionID, metricKey, agg)
+	return args.Get(0).(float64), args.Error(1)
+}
+
+func (m *MockDataService) GetDistinctMetricApps(startTime, endTime *time.Time) ([]models.AppInfo, error) {
+	args := m.Called(startTime, endTime)
+	return args.Get(0).([]models.AppInfo), args.Error(1)
+}
+
+func (m *MockDataService) GetSpansWithMetrics(sessionID string, scope string) ([]models.SpanMetricCount, error) {
+	args := m.Called(sessionID, scope)
+	return args.Get(0).([]models.SpanMetricCount), args.Error(1)
+}
+
+func (m *MockDataService) GetMetricMatrix(keys []string, scope string) (models.MetricMatrix, error) {
+	args := m.Called(keys, scope)
+	return args.Get(0).(models.MetricMatrix), args.Error(1)
+}
+
 func (m *MockDataService) GetTracesBySessionID(sessionID string) ([]models.OtelTraces, error) {
 	args := m.Called(sessionID)
 	return args.Get(0).([]models.OtelTraces), args.Error(1)
 }
 
+func (m *MockDataService) StreamTracesBySessionID(sessionID string, fn func(models.OtelTraces) error) error {
+	args := m.Called(sessionID, fn)
+	for _, trace := range args.Get(0).([]models.OtelTraces) {
+		if err := fn(trace); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func (m *MockDataService) GetSessionIDSUniqueWithPagination(startTime, endTime time.Time, page, limit int, nameFilter *string, cursor string) ([]models.SessionUniqueID, int, string, error) {
+	args := m.Called(startTime, endTime, page, limit, nameFilter, cursor)
+	return args.Get(0).([]models.SessionUniqueID), args.Int(1), args.String(2), args.Error(3)
+}
+
 func (m *MockDataService) GetSessionIDSWithPrompts(startTime, endTime time.Time) ([]models.SessionUniqueID, error) {
 	args := m.Called(startTime, endTime)
 	return args.Get(0).([]models.SessionUniqueID), args.Error(1)
 }
 
+func (m *MockDataService) PurgeSession(sessionID string) (models.PurgeResult, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).(models.PurgeResult), args.Error(1)
+}
+
+func (m *MockDataService) CheckSessionsExist(sessionIDs []string) ([]string, []string, error) {
+	args := m.Called(sessionIDs)
+	return args.Get(0).([]string), args.Get(1).([]string), args.Error(2)
+}
+
+func (m *MockDataService) GetSessionConversation(sessionID string) ([]models.ConversationMessage, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).([]models.ConversationMessage), args.Error(1)
+}
+
+func (m *MockDataService) GetSessionSpanCounts(sessionIDs []string) (map[string]models.SessionSpanCount, error) {
+	args := m.Called(sessionIDs)
+	return args.Get(0).(map[string]models.SessionSpanCount), args.Error(1)
+}
+
 func (m *MockDataService) GetTracesBySessionIDs(sessionIDs []string) (map[string][]models.OtelTraces, []string, error) {
 	args := m.Called(sessionIDs)
 	return args.Get(0).(map[string][]models.OtelTraces), args.Get(1).([]string), args.Error(2)
@@ -68,6 +134,41 @@ func (m *MockDataService) GetSpanBySessionIDAndSpanID(sessionID string, spanID s
 	return args.Get(0).(models.OtelTraces), args.Error(1)
 }
 
+func (m *MockDataService) GetTraceStats(traceID string) (models.TraceStats, error) {
+	args := m.Called(traceID)
+	return args.Get(0).(models.TraceStats), args.Error(1)
+}
+
+func (m *MockDataService) GetTraceAttributeKeys(serviceName *string, startTime, endTime time.Time, limit int) ([]string, error) {
+	args := m.Called(serviceName, startTime, endTime, limit)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockDataService) GetCallGraphEdges(sessionID string) ([]models.CallGraphEdge, error) {
+	args := m.Called(sessionID)
+	return args.Get(0).([]models.CallGraphEdge), args.Error(1)
+}
+
+func (m *MockDataService) GetSessionEvents(sessionID string, eventName *string) ([]models.SpanEvent, error) {
+	args := m.Called(sessionID, eventName)
+	return args.Get(0).([]models.SpanEvent), args.Error(1)
+}
+
+func (m *MockDataService) GetTokenUsagePerSession(startTime, endTime time.Time, page, limit int) ([]models.SessionTokenUsage, int, error) {
+	args := m.Called(startTime, endTime, page, limit)
+	return args.Get(0).([]models.SessionTokenUsage), args.Int(1), args.Error(2)
+}
+
+func (m *MockDataService) GetGraphDeterminism(appName string, startTime, endTime time.Time) (models.GraphDeterminism, error) {
+	args := m.Called(appName, startTime, endTime)
+	return args.Get(0).(models.GraphDeterminism), args.Error(1)
+}
+
+func (m *MockDataService) GetSpanDurationStats(serviceName *string, startTime, endTime time.Time) ([]models.SpanDurationStat, error) {
+	args := m.Called(serviceName, startTime, endTime)
+	return args.Get(0).([]models.SpanDurationStat), args.Error(1)
+}
+
 // Helper function to create test server
 func createTestServer(mockDataService *MockDataService) *HttpServer {
 	return &HttpServer{
@@ -82,15 +183,32 @@ func createTestServer(mockDataService *MockDataService) *HttpServer {
 func createTestRouter(server *HttpServer) *mux.Router {
 	router := mux.NewRouter()
 	router.HandleFunc("/keepAlive", KeepAlive).Methods(http.MethodGet)
+	router.HandleFunc("/ready", server.Ready).Methods(http.MethodGet)
 	router.HandleFunc("/metrics", PrometeusMetrics).Methods(http.MethodGet)
 	router.HandleFunc("/traces/sessions/spans", server.SessionSpans).Methods(http.MethodGet)
+	router.HandleFunc("/traces/sessions/exists", server.SessionsExist).Methods(http.MethodPost)
 	router.HandleFunc("/traces/sessions", server.Sessions).Methods(http.MethodGet)
+	router.HandleFunc("/traces/attribute-keys", server.TraceAttributeKeys).Methods(http.MethodGet)
+	router.HandleFunc("/traces/schema", server.TraceSchema).Methods(http.MethodGet)
+	router.HandleFunc("/insights/spans/durations", server.SpanDurations).Methods(http.MethodGet)
+	router.HandleFunc("/insights/tokens/sessions", server.SessionTokenUsage).Methods(http.MethodGet)
+	router.HandleFunc("/insights/graph-determinism", server.GraphDeterminism).Methods(http.MethodGet)
+	router.HandleFunc("/insights/metrics/matrix", server.MetricMatrix).Methods(http.MethodGet)
 	router.HandleFunc("/traces/session/{session_id}", server.Traces).Methods(http.MethodGet)
 	router.HandleFunc("/metrics/session", server.WriteMetricsSession).Methods(http.MethodPost)
 	router.HandleFunc("/metrics/span", server.WriteMetricsSpan).Methods(http.MethodPost)
+	router.HandleFunc("/metrics/apps", server.MetricApps).Methods(http.MethodGet)
+	router.HandleFunc("/metrics/compare", server.MetricsCompare).Methods(http.MethodGet)
+	router.HandleFunc("/metrics/spans", server.MetricSpans).Methods(http.MethodGet)
 	router.HandleFunc("/metrics/session/{session_id}", server.GetMetricsSession).Methods(http.MethodGet)
+	router.HandleFunc("/metrics/session/{session_id}/rollup", server.MetricsSessionRollup).Methods(http.MethodGet)
 	router.HandleFunc("/metrics/span/{span_id}", server.GetMetricsSpan).Methods(http.MethodGet)
 	router.HandleFunc("/traces/session/{session_id}/span/{span_id}", server.SpanBySessionAndSpanID).Methods(http.MethodGet)
+	router.HandleFunc("/traces/trace/{trace_id}/stats", server.TraceStats).Methods(http.MethodGet)
+	router.HandleFunc("/traces/session/{session_id}/callgraph/edges", server.CallGraphEdges).Methods(http.MethodGet)
+	router.HandleFunc("/traces/session/{session_id}/events", server.SessionEvents).Methods(http.MethodGet)
+	router.HandleFunc("/sessions/{session_id}", server.PurgeSession).Methods(http.MethodDelete)
+	router.HandleFunc("/sessions/{session_id}/conversation", server.SessionConversation).Methods(http.MethodGet)
 	return router
 }
 
@@ -192,6 +310,308 @@ func TestKeepAlive_ResponseFormat(t *testing.T) {
 	})
 }
 
+func TestTraceSchema(t *testing.T) {
+	t.Run("GET /traces/schema should list every trace field", func(t *testing.T) {
+		server := createTestServer(new(MockDataService))
+		router := createTestRouter(server)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/schema", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response []models.TraceField
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Len(t, response, reflect.TypeOf(models.OtelTraces{}).NumField())
+		assert.Contains(t, response, models.TraceField{Field: "EventsName", Column: "Events.Name", JSONKey: "EventsName", Type: "Array(LowCardinality(String))"})
+	})
+}
+
+func TestReady(t *testing.T) {
+	t.Run("GET /ready should report every component up", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("Ping").Return(nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"status": "ready", "components": {"data": {"status": "up", "required": true}}}`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /ready with the data service down should return service unavailable", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("Ping").Return(errors.New("connection refused"))
+
+		req := httptest.NewRequest(http.MethodGet, "/ready", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"status": "not_ready", "components": {"data": {"status": "down", "required": true, "error": "connection refused"}}}`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("POST /ready should return method not allowed", func(t *testing.T) {
+		server := createTestServer(new(MockDataService))
+
+		req := httptest.NewRequest(http.MethodPost, "/ready", nil)
+		w := httptest.NewRecorder()
+
+		server.Ready(w, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
+
+func TestInfo(t *testing.T) {
+	t.Run("GET /info should report the session id strategy", func(t *testing.T) {
+		server := createTestServer(new(MockDataService))
+		server.SessionIDStrategy = common.SESSION_ID_STRATEGY_REGEX
+		server.SessionIDRegex = `_(.+)$`
+
+		req := httptest.NewRequest(http.MethodGet, "/info", nil)
+		w := httptest.NewRecorder()
+
+		server.Info(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response InfoResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, InfoResponse{SessionIDStrategy: "regex", SessionIDRegex: `_(.+)$`}, response)
+	})
+
+	t.Run("GET /info should default to the raw strategy", func(t *testing.T) {
+		server := createTestServer(new(MockDataService))
+
+		req := httptest.NewRequest(http.MethodGet, "/info", nil)
+		w := httptest.NewRecorder()
+
+		server.Info(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"session_id_strategy":"raw"}`, w.Body.String())
+	})
+
+	t.Run("POST /info should return method not allowed", func(t *testing.T) {
+		server := createTestServer(new(MockDataService))
+
+		req := httptest.NewRequest(http.MethodPost, "/info", nil)
+		w := httptest.NewRecorder()
+
+		server.Info(w, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
+
+func TestPrettyJSON(t *testing.T) {
+	t.Run("Responses should be compact by default", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/keepAlive", nil)
+		w := httptest.NewRecorder()
+
+		KeepAlive(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "{\"message\":\"I'm alive!\"}\n", w.Body.String())
+	})
+
+	t.Run("Responses should be indented with pretty=true", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/keepAlive?pretty=true", nil)
+		w := httptest.NewRecorder()
+
+		KeepAlive(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "{\n  \"message\": \"I'm alive!\"\n}\n", w.Body.String())
+	})
+
+	t.Run("Status code and content type should be kept with pretty=true", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", common.METRIC_SCOPE_SESSION, 0, 0, common.SORT_TIMESTAMP_DESC).Return([]models.Metric{
+			{ID: stringPtr("metric_001"), Metrics: jsonRawMessagePtr(`{"accuracy":"0.95"}`)},
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123?pretty=true", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "[\n  {\n    \"id\": \"metric_001\",")
+		assert.Contains(t, w.Body.String(), "\"metrics\": {\n      \"accuracy\": \"0.95\"\n    },")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestQueryParamValidation(t *testing.T) {
+	startTime := "2023-06-25T15:04:05Z"
+	endTime := "2023-06-25T18:04:05Z"
+
+	tests := []struct {
+		name          string
+		url           string
+		expectedError string
+	}{
+		{"sessions without start_time", "/traces/sessions?end_time=" + endTime, "start_time parameter is required"},
+		{"sessions without end_time", "/traces/sessions?start_time=" + startTime, "end_time parameter is required"},
+		{"sessions with invalid start_time", "/traces/sessions?start_time=invalid&end_time=" + endTime, "Invalid start_time: invalid date format, must be in ISO 8601 format"},
+		{"attribute keys without end_time", "/traces/attribute-keys?start_time=" + startTime, "end_time parameter is required"},
+		{"token usage without start_time", "/insights/tokens/sessions?end_time=" + endTime, "start_time parameter is required"},
+		{"token usage with reversed window", "/insights/tokens/sessions?start_time=" + endTime + "&end_time=" + startTime, "Invalid time window: end_time is before start_time"},
+		{"graph determinism without app_name", "/insights/graph-determinism?start_time=" + startTime + "&end_time=" + endTime, "app_name parameter is required"},
+		{"graph determinism without end_time", "/insights/graph-determinism?app_name=ml-service&start_time=" + startTime, "end_time parameter is required"},
+		{"span durations without start_time", "/insights/spans/durations?end_time=" + endTime, "start_time parameter is required"},
+		{"metric apps with invalid end_time", "/metrics/apps?end_time=invalid", "Invalid end_time: invalid date format, must be in ISO 8601 format"},
+		{"sessions with invalid limit", "/traces/sessions?start_time=" + startTime + "&end_time=" + endTime + "&limit=0", "Invalid limit: must be a positive integer"},
+		{"sessions with include_prompts and cursor", "/traces/sessions?start_time=" + startTime + "&end_time=" + endTime + "&include_prompts=true&cursor=abc", "include_prompts cannot be combined with page, limit or cursor"},
+		{"attribute keys with invalid limit", "/traces/attribute-keys?start_time=" + startTime + "&end_time=" + endTime + "&limit=-1", "Invalid limit: must be a positive integer"},
+		{"token usage with invalid page", "/insights/tokens/sessions?start_time=" + startTime + "&end_time=" + endTime + "&page=-1", "Invalid page: must be a non-negative integer"},
+		{"session metrics with invalid sort", "/metrics/session/session_abc123?sort=name", "Invalid sort: must be timestamp_asc or timestamp_desc"},
+		{"session metrics with invalid scope", "/metrics/session/session_abc123?scope=trace", `Invalid scope "trace": must be session or span`},
+		{"span metrics with invalid page", "/metrics/span/span_abc123?page=x", "Invalid page: must be a non-negative integer"},
+		{"rollup without key", "/metrics/session/session_abc123/rollup?agg=sum", "key parameter is required"},
+		{"rollup with invalid agg", "/metrics/session/session_abc123/rollup?key=latency_ms&agg=median", `Invalid agg "median": must be sum, avg, min or max`},
+		{"compare without session_b", "/metrics/compare?session_a=session_abc123", "session_a and session_b parameters are required"},
+		{"compare with invalid scope", "/metrics/compare?session_a=session_abc123&session_b=session_def456&scope=trace", `Invalid scope "trace": must be session or span`},
+		{"metric spans without session_id", "/metrics/spans", "session_id parameter is required"},
+		{"metric matrix without keys", "/insights/metrics/matrix", "keys parameter is required"},
+		{"metric matrix with invalid scope", "/insights/metrics/matrix?keys=accuracy&scope=trace", `Invalid scope "trace": must be session or span`},
+		{"session spans without session_ids", "/traces/sessions/spans", "session_ids parameter is required"},
+		{"session spans with only separators", "/traces/sessions/spans?session_ids=,%20,", "No valid session IDs provided"},
+		{"session spans with too many ids", "/traces/sessions/spans?session_ids=" + strings.Repeat("session,", 51) + "session", "Too many session IDs provided (maximum 50)"},
+		{"traces with unknown fields", "/traces/session/session_abc123?fields=SpanName,Cost", "Invalid fields: unknown trace fields: Cost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var response ErrorResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedError, response.Error)
+
+			mockDataService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	t.Run("Responses should be bare by default", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/keepAlive", nil)
+		w := httptest.NewRecorder()
+
+		KeepAlive(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"message": "I'm alive!"}`, w.Body.String())
+	})
+
+	t.Run("Objects should be wrapped with the envelope header", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/keepAlive", nil)
+		req.Header.Set(common.RESPONSE_ENVELOPE_HEADER, "true")
+		w := httptest.NewRecorder()
+
+		KeepAlive(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"data": {"message": "I'm alive!"}, "error": null}`, w.Body.String())
+	})
+
+	t.Run("Arrays and paginated responses should be wrapped with the envelope header", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", common.METRIC_SCOPE_SESSION, 0, 0, common.SORT_TIMESTAMP_DESC).Return([]models.Metric{
+			{ID: stringPtr("metric_001"), Metrics: jsonRawMessagePtr(`{"accuracy":"0.95"}`)},
+		}, nil)
+		mockDataService.On("GetTraceStats", "trace_ghi789").Return(models.TraceStats{TraceId: "trace_ghi789", SpanCount: 1, Services: []string{}}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123", nil)
+		req.Header.Set(common.RESPONSE_ENVELOPE_HEADER, "true")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var envelope struct {
+			Data  []map[string]interface{} `json:"data"`
+			Error *string                  `json:"error"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &envelope))
+		if assert.Len(t, envelope.Data, 1) {
+			assert.Equal(t, "metric_001", envelope.Data[0]["id"])
+		}
+		assert.Nil(t, envelope.Error)
+
+		req = httptest.NewRequest(http.MethodGet, "/traces/trace/trace_ghi789/stats", nil)
+		req.Header.Set(common.RESPONSE_ENVELOPE_HEADER, "true")
+		w = httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data": {"trace_id": "trace_ghi789", "span_count": 1, "error_span_count": 0, "services": [], "total_duration_ms": 0}, "error": null}`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("Error responses should not be wrapped", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.ReadOnly = true
+		router := createTestRouter(server)
+		router.Use(server.readOnlyMiddleware)
+
+		req := httptest.NewRequest(http.MethodDelete, "/sessions/session_abc123", nil)
+		req.Header.Set(common.RESPONSE_ENVELOPE_HEADER, "true")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.JSONEq(t, `{"message": "The API is in read-only mode, write operations are disabled"}`, w.Body.String())
+	})
+}
+
 func TestPrometeusMetrics(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -370,62 +790,149 @@ func TestSessions(t *testing.T) {
 		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("GET /traces/sessions with invalid start_time should return bad request", func(t *testing.T) {
+	t.Run("GET /traces/sessions should not include counts by default", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 
-		req := httptest.NewRequest(http.MethodGet, "/traces/sessions?start_time=invalid&end_time=2023-06-25T18:04:05Z", nil)
+		mockDataService.On("GetSessionIDSUnique", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]models.SessionUniqueID{
+			{ID: "session_abc123", StartTimestamp: "2023-06-25T15:30:00Z"},
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/sessions?start_time=2023-06-25T15:00:00Z&end_time=2023-06-25T18:00:00Z", nil)
 		w := httptest.NewRecorder()
 
 		server.Sessions(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "Invalid start_time")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "span_count")
+		assert.NotContains(t, w.Body.String(), "error_count")
+
+		mockDataService.AssertExpectations(t)
+		mockDataService.AssertNotCalled(t, "GetSessionSpanCounts", mock.Anything)
 	})
 
-	t.Run("GET /traces/sessions with invalid end_time should return bad request", func(t *testing.T) {
+	t.Run("GET /traces/sessions with include_counts should add span and error counts", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 
-		req := httptest.NewRequest(http.MethodGet, "/traces/sessions?start_time=2023-06-25T15:04:05Z&end_time=invalid", nil)
+		mockDataService.On("GetSessionIDSUnique", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]models.SessionUniqueID{
+			{ID: "session_abc123", StartTimestamp: "2023-06-25T15:30:00Z"},
+			{ID: "session_def456", StartTimestamp: "2023-06-25T16:15:00Z"},
+		}, nil)
+		mockDataService.On("GetSessionSpanCounts", []string{"session_abc123", "session_def456"}).Return(map[string]models.SessionSpanCount{
+			"session_abc123": {ID: "session_abc123", SpanCount: 12, ErrorCount: 2},
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/sessions?start_time=2023-06-25T15:00:00Z&end_time=2023-06-25T18:00:00Z&include_counts=true", nil)
 		w := httptest.NewRecorder()
 
 		server.Sessions(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "Invalid end_time")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"data":[
+			{"id":"session_abc123","start_timestamp":"2023-06-25T15:30:00Z","span_count":12,"error_count":2},
+			{"id":"session_def456","start_timestamp":"2023-06-25T16:15:00Z","span_count":0,"error_count":0}
+		],"total":2}`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("GET /traces/sessions with service error should return internal server error", func(t *testing.T) {
+	t.Run("GET /traces/sessions with include_counts and service error should return internal server error", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 
-		mockDataService.On("GetSessionIDSUnique", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]models.SessionUniqueID{}, errors.New("database error"))
+		mockDataService.On("GetSessionIDSUnique", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]models.SessionUniqueID{
+			{ID: "session_abc123", StartTimestamp: "2023-06-25T15:30:00Z"},
+		}, nil)
+		mockDataService.On("GetSessionSpanCounts", []string{"session_abc123"}).Return(map[string]models.SessionSpanCount(nil), errors.New("database error"))
 
-		url := "/traces/sessions?start_time=2023-06-25T15:04:05Z&end_time=2023-06-25T18:04:05Z"
-		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req := httptest.NewRequest(http.MethodGet, "/traces/sessions?start_time=2023-06-25T15:00:00Z&end_time=2023-06-25T18:00:00Z&include_counts=true", nil)
 		w := httptest.NewRecorder()
 
 		server.Sessions(w, req)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
-		assert.Contains(t, w.Body.String(), "Error fetching sessions")
+		assert.Contains(t, w.Body.String(), "Error fetching session span counts")
 
 		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("GET /traces/sessions with include_prompts=true should return sessions with prompts", func(t *testing.T) {
+	t.Run("GET /traces/sessions with offset times should query in UTC", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 
-		startTime := time.Date(2023, 6, 25, 15, 0, 0, 0, time.UTC)
-		endTime := time.Date(2023, 6, 25, 18, 0, 0, 0, time.UTC)
-
-		expectedSessions := []models.SessionUniqueID{
-			{ID: "session_abc123", StartTimestamp: "2023-06-25T15:30:00Z", Prompt: "hello"},
-		}
+		expectedStart := time.Date(2023, 6, 25, 15, 0, 0, 0, time.UTC)
+		expectedEnd := time.Date(2023, 6, 25, 18, 0, 0, 0, time.UTC)
 
-		mockDataService.On("GetSessionIDSWithPrompts", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(expectedSessions, nil)
+		mockDataService.On("GetSessionIDSUnique", expectedStart, expectedEnd).Return([]models.SessionUniqueID{}, nil)
+
+		url := "/traces/sessions?start_time=2023-06-25T17:00:00%2B02:00&end_time=2023-06-25T20:00:00%2B02:00"
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		server.Sessions(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/sessions with invalid start_time should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/sessions?start_time=invalid&end_time=2023-06-25T18:04:05Z", nil)
+		w := httptest.NewRecorder()
+
+		server.Sessions(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid start_time")
+	})
+
+	t.Run("GET /traces/sessions with invalid end_time should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/sessions?start_time=2023-06-25T15:04:05Z&end_time=invalid", nil)
+		w := httptest.NewRecorder()
+
+		server.Sessions(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid end_time")
+	})
+
+	t.Run("GET /traces/sessions with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		mockDataService.On("GetSessionIDSUnique", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]models.SessionUniqueID{}, errors.New("database error"))
+
+		url := "/traces/sessions?start_time=2023-06-25T15:04:05Z&end_time=2023-06-25T18:04:05Z"
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		server.Sessions(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching sessions")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/sessions with include_prompts=true should return sessions with prompts", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		startTime := time.Date(2023, 6, 25, 15, 0, 0, 0, time.UTC)
+		endTime := time.Date(2023, 6, 25, 18, 0, 0, 0, time.UTC)
+
+		expectedSessions := []models.SessionUniqueID{
+			{ID: "session_abc123", StartTimestamp: "2023-06-25T15:30:00Z", Prompt: "hello"},
+		}
+
+		mockDataService.On("GetSessionIDSWithPrompts", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(expectedSessions, nil)
 
 		url := fmt.Sprintf("/traces/sessions?start_time=%s&end_time=%s&include_prompts=true",
 			startTime.Format(time.RFC3339),
@@ -514,6 +1021,139 @@ func TestTraces(t *testing.T) {
 		mockDataService.AssertExpectations(t)
 	})
 
+	t.Run("GET /traces/session/{session_id} with NDJSON accept header should stream spans", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		sessionID := "session_abc123"
+		expectedTraces := []models.OtelTraces{
+			{TraceId: "trace_def456", SpanId: "span_1", SpanName: "ml_inference", Timestamp: time.Date(2023, 6, 25, 15, 30, 0, 0, time.UTC)},
+			{TraceId: "trace_def456", SpanId: "span_2", SpanName: "data_processing", Timestamp: time.Date(2023, 6, 25, 15, 31, 0, 0, time.UTC)},
+		}
+
+		mockDataService.On("StreamTracesBySessionID", sessionID, mock.Anything).Return(expectedTraces, nil)
+
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/traces/session/%s", sessionID), nil)
+		req.Header.Set("Accept", common.CONTENT_TYPE_NDJSON)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, common.CONTENT_TYPE_NDJSON, w.Header().Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		assert.Len(t, lines, len(expectedTraces))
+		for i, line := range lines {
+			var span models.OtelTraces
+			err := json.Unmarshal([]byte(line), &span)
+			assert.NoError(t, err)
+			assert.Equal(t, expectedTraces[i], span)
+		}
+
+		mockDataService.AssertExpectations(t)
+		mockDataService.AssertNotCalled(t, "GetTracesBySessionID", mock.Anything)
+	})
+
+	t.Run("GET /traces/session/{session_id} with NDJSON and no spans should return empty body", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("StreamTracesBySessionID", "session_empty", mock.Anything).Return([]models.OtelTraces{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_empty", nil)
+		req.Header.Set("Accept", common.CONTENT_TYPE_NDJSON)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, common.CONTENT_TYPE_NDJSON, w.Header().Get("Content-Type"))
+		assert.Empty(t, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/session/{session_id} with NDJSON and service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("StreamTracesBySessionID", "session_abc123", mock.Anything).Return([]models.OtelTraces{}, errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123", nil)
+		req.Header.Set("Accept", common.CONTENT_TYPE_NDJSON)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching traces")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/session/{session_id} with fields should only serialize those fields", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		traces := []models.OtelTraces{
+			{TraceId: "trace_def456", SpanName: "ml_inference", Duration: 1500, ServiceName: "ml-service"},
+			{TraceId: "trace_def456", SpanName: "data_processing", Duration: 300, ServiceName: "ml-service"},
+		}
+		mockDataService.On("GetTracesBySessionID", "session_abc123").Return(traces, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123?fields=SpanName,%20Duration,SpanName", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `[{"SpanName":"ml_inference","Duration":1500},{"SpanName":"data_processing","Duration":300}]`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/session/{session_id} with fields should project streamed spans", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		traces := []models.OtelTraces{
+			{TraceId: "trace_def456", SpanId: "span_1", SpanName: "ml_inference", Duration: 1500},
+		}
+		mockDataService.On("StreamTracesBySessionID", "session_abc123", mock.Anything).Return(traces, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123?fields=SpanId", nil)
+		req.Header.Set("Accept", common.CONTENT_TYPE_NDJSON)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "{\"SpanId\":\"span_1\"}\n", w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/session/{session_id} with unknown fields should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123?fields=SpanName,span_name,Cost", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unknown trace fields: span_name, Cost")
+		mockDataService.AssertNotCalled(t, "GetTracesBySessionID", mock.Anything)
+	})
+
 	t.Run("POST /traces/session/{session_id} should return method not allowed", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
@@ -529,284 +1169,2010 @@ func TestTraces(t *testing.T) {
 	})
 }
 
-func TestWriteMetricsSession(t *testing.T) {
-	t.Run("POST /metrics/session with valid payload should create metric", func(t *testing.T) {
+func TestSessionsPagination(t *testing.T) {
+	startTime := time.Date(2023, 6, 25, 15, 0, 0, 0, time.UTC)
+	endTime := time.Date(2023, 6, 25, 18, 0, 0, 0, time.UTC)
+	baseURL := fmt.Sprintf("/traces/sessions?start_time=%s&end_time=%s",
+		startTime.Format(time.RFC3339),
+		endTime.Format(time.RFC3339))
+
+	t.Run("GET /traces/sessions with limit should return a page with the next cursor", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 
-		spanID := "span_abc123"
-		traceID := "trace_def456"
-		sessionID := "session_ghi789"
-		appName := "ml-service"
-		appID := "app-001"
-		metricsJSON := models.JSONRawMessage(`{"accuracy":"0.95","latency_ms":"120"}`)
-
-		metricRequest := models.MetricCreateRequest{
-			SpanId:    &spanID,
-			TraceId:   &traceID,
-			SessionId: &sessionID,
-			Metrics:   &metricsJSON,
-			AppName:   &appName,
-			AppId:     &appID,
-		}
-
-		// Create expected metric with generated ID and timestamp
-		expectedMetric := models.Metric{
-			ID:        stringPtr("generated-uuid"),
-			SpanId:    &spanID,
-			TraceId:   &traceID,
-			SessionId: &sessionID,
-			TimeStamp: timePtr(time.Now()),
-			Metrics:   &metricsJSON,
-			AppName:   &appName,
-			AppId:     &appID,
-			Scope:     stringPtr(common.METRIC_SCOPE_SESSION),
+		expectedSessions := []models.SessionUniqueID{
+			{ID: "session_def456", StartTimestamp: "2023-06-25T16:15:00Z"},
+			{ID: "session_abc123", StartTimestamp: "2023-06-25T15:30:00Z"},
 		}
+		mockDataService.On("GetSessionIDSUniqueWithPagination", startTime, endTime, 0, 2, (*string)(nil), "").Return(expectedSessions, 5, "cursor_abc", nil)
 
-		mockDataService.On("AddMetric", mock.MatchedBy(func(m models.Metric) bool {
-			return *m.SpanId == spanID && *m.TraceId == traceID && *m.SessionId == sessionID &&
-				*m.AppName == appName && *m.AppId == appID && *m.Scope == common.METRIC_SCOPE_SESSION
-		})).Return(expectedMetric, nil)
-
-		body, _ := json.Marshal(metricRequest)
-		req := httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
+		req := httptest.NewRequest(http.MethodGet, baseURL+"&limit=2", nil)
 		w := httptest.NewRecorder()
 
-		server.WriteMetricsSession(w, req)
+		server.Sessions(w, req)
 
-		assert.Equal(t, http.StatusCreated, w.Code)
-		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Equal(t, http.StatusOK, w.Code)
 
-		var response models.MetricResponse
+		var response models.SessionsResponse
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, expectedMetric.ID, response.ID)
-		assert.Equal(t, expectedMetric.SpanId, response.SpanId)
+		assert.Equal(t, expectedSessions, response.Data)
+		if assert.NotNil(t, response.Total) {
+			assert.Equal(t, 5, *response.Total)
+		}
+		assert.Equal(t, "cursor_abc", response.NextCursor)
 
 		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("POST /metrics/session with invalid JSON should return bad request", func(t *testing.T) {
+	t.Run("GET /traces/sessions with cursor should pass it with the default limit", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 
-		req := httptest.NewRequest(http.MethodPost, "/metrics/session", strings.NewReader("invalid json"))
-		req.Header.Set("Content-Type", "application/json")
+		mockDataService.On("GetSessionIDSUniqueWithPagination", startTime, endTime, 0, 100, (*string)(nil), "cursor_abc").Return([]models.SessionUniqueID{}, 0, "", nil)
+
+		req := httptest.NewRequest(http.MethodGet, baseURL+"&cursor=cursor_abc", nil)
 		w := httptest.NewRecorder()
 
-		server.WriteMetricsSession(w, req)
+		server.Sessions(w, req)
 
-		assert.Equal(t, http.StatusBadRequest, w.Code)
-		assert.Contains(t, w.Body.String(), "Error decoding request body")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "next_cursor")
+		assert.NotContains(t, w.Body.String(), "total")
+
+		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("POST /metrics/session with service error should return internal server error", func(t *testing.T) {
+	t.Run("GET /traces/sessions with page should keep offset pagination", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 
-		spanID := "span_abc123"
-		traceID := "trace_def456"
-		sessionID := "session_ghi789"
-		appName := "ml-service"
-		appID := "app-001"
-		metricsJSON := models.JSONRawMessage(`{"accuracy":"0.95"}`)
+		mockDataService.On("GetSessionIDSUniqueWithPagination", startTime, endTime, 3, 1000, (*string)(nil), "").Return([]models.SessionUniqueID{}, 5, "", nil)
 
-		metricRequest := models.MetricCreateRequest{
-			SpanId:    &spanID,
-			TraceId:   &traceID,
-			SessionId: &sessionID,
-			Metrics:   &metricsJSON,
-			AppName:   &appName,
-			AppId:     &appID,
-		}
+		req := httptest.NewRequest(http.MethodGet, baseURL+"&page=3&limit=5000", nil)
+		w := httptest.NewRecorder()
 
-		mockDataService.On("AddMetric", mock.AnythingOfType("models.Metric")).Return(models.Metric{}, errors.New("database error"))
+		server.Sessions(w, req)
 
-		body, _ := json.Marshal(metricRequest)
-		req := httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/sessions with invalid cursor should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		mockDataService.On("GetSessionIDSUniqueWithPagination", startTime, endTime, 0, 100, (*string)(nil), "garbage").Return([]models.SessionUniqueID{}, 0, "", models.ErrInvalidCursor)
+
+		req := httptest.NewRequest(http.MethodGet, baseURL+"&cursor=garbage", nil)
+		w := httptest.NewRecorder()
+
+		server.Sessions(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "Invalid cursor")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/sessions with invalid page options should return bad request", func(t *testing.T) {
+		for _, params := range []string{"&page=-1", "&limit=0", "&limit=abc", "&limit=10&include_prompts=true"} {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+
+			req := httptest.NewRequest(http.MethodGet, baseURL+params, nil)
+			w := httptest.NewRecorder()
+
+			server.Sessions(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, params)
+			mockDataService.AssertNotCalled(t, "GetSessionIDSUniqueWithPagination")
+		}
+	})
+}
+
+func TestSessionsExist(t *testing.T) {
+	t.Run("POST /traces/sessions/exists should split present and missing sessions", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("CheckSessionsExist", []string{"session_abc123", "session_missing", "session_def456"}).
+			Return([]string{"session_abc123", "session_def456"}, []string{"session_missing"}, nil)
+
+		body := `{"session_ids":["session_abc123"," session_missing ","","session_def456"]}`
+		req := httptest.NewRequest(http.MethodPost, "/traces/sessions/exists", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response models.SessionsExistResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"session_abc123", "session_def456"}, response.Present)
+		assert.Equal(t, []string{"session_missing"}, response.Missing)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("POST /traces/sessions/exists with invalid body should return bad request", func(t *testing.T) {
+		server := createTestServer(new(MockDataService))
+		router := createTestRouter(server)
+
+		req := httptest.NewRequest(http.MethodPost, "/traces/sessions/exists", bytes.NewBufferString("invalid json"))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid request body")
+	})
+
+	t.Run("POST /traces/sessions/exists without session ids should return bad request", func(t *testing.T) {
+		server := createTestServer(new(MockDataService))
+		router := createTestRouter(server)
+
+		req := httptest.NewRequest(http.MethodPost, "/traces/sessions/exists", bytes.NewBufferString(`{"session_ids":[" "]}`))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "No valid session IDs provided")
+	})
+
+	t.Run("POST /traces/sessions/exists with too many session ids should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		ids := make([]string, common.SESSIONS_EXIST_MAX_IDS+1)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("session_%d", i)
+		}
+		body, _ := json.Marshal(models.SessionsExistRequest{SessionIDs: ids})
+		req := httptest.NewRequest(http.MethodPost, "/traces/sessions/exists", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Too many session IDs provided")
+		mockDataService.AssertNotCalled(t, "CheckSessionsExist", mock.Anything)
+	})
+
+	t.Run("POST /traces/sessions/exists with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("CheckSessionsExist", []string{"session_abc123"}).Return([]string(nil), []string(nil), errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodPost, "/traces/sessions/exists", bytes.NewBufferString(`{"session_ids":["session_abc123"]}`))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error checking sessions")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestTraceAttributeKeys(t *testing.T) {
+	startTime := "2023-06-25T15:04:05Z"
+	endTime := "2023-06-25T18:04:05Z"
+	expectedKeys := []string{"gen_ai.prompt.0.content", "llm.usage.total_tokens", "session.id"}
+
+	t.Run("GET /traces/attribute-keys should return keys with default limit", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetTraceAttributeKeys", (*string)(nil), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), common.ATTRIBUTE_KEYS_DEFAULT_LIMIT).Return(expectedKeys, nil)
+
+		url := fmt.Sprintf("/traces/attribute-keys?start_time=%s&end_time=%s", startTime, endTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response []string
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedKeys, response)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/attribute-keys should pass service name and cap limit", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetTraceAttributeKeys", mock.MatchedBy(func(serviceName *string) bool {
+			return serviceName != nil && *serviceName == "ml-service"
+		}), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), common.ATTRIBUTE_KEYS_MAX_LIMIT).Return(expectedKeys[:1], nil)
+
+		url := fmt.Sprintf("/traces/attribute-keys?start_time=%s&end_time=%s&service_name=ml-service&limit=5000", startTime, endTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/attribute-keys with invalid limit should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		url := fmt.Sprintf("/traces/attribute-keys?start_time=%s&end_time=%s&limit=abc", startTime, endTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid limit")
+	})
+
+	t.Run("GET /traces/attribute-keys without start_time should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		url := fmt.Sprintf("/traces/attribute-keys?end_time=%s", endTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "start_time parameter is required")
+	})
+
+	t.Run("GET /traces/attribute-keys with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetTraceAttributeKeys", (*string)(nil), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), common.ATTRIBUTE_KEYS_DEFAULT_LIMIT).Return([]string{}, errors.New("database error"))
+
+		url := fmt.Sprintf("/traces/attribute-keys?start_time=%s&end_time=%s", startTime, endTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching trace attribute keys")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestSessionTokenUsage(t *testing.T) {
+	startTime := time.Date(2023, 6, 25, 15, 4, 5, 0, time.UTC)
+	endTime := time.Date(2023, 6, 25, 18, 4, 5, 0, time.UTC)
+	window := "start_time=2023-06-25T15:04:05Z&end_time=2023-06-25T18:04:05Z"
+
+	t.Run("GET /insights/tokens/sessions should return the usage of every session", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		expectedUsage := []models.SessionTokenUsage{
+			{SessionID: "session_def456", TotalTokens: 4200},
+			{SessionID: "session_abc123", TotalTokens: 1520},
+			{SessionID: "session_ghi789", TotalTokens: 80},
+		}
+		mockDataService.On("GetTokenUsagePerSession", startTime, endTime, 0, 100).Return(expectedUsage, 3, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/insights/tokens/sessions?"+window, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response models.SessionTokenUsageResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedUsage, response.Data)
+		assert.Equal(t, 3, response.Total)
+		assert.Contains(t, w.Body.String(), `{"session_id":"session_def456","total_tokens":4200}`)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /insights/tokens/sessions should pass page and limit", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetTokenUsagePerSession", startTime, endTime, 1, 2).Return([]models.SessionTokenUsage{{SessionID: "session_ghi789", TotalTokens: 80}}, 3, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/insights/tokens/sessions?"+window+"&page=1&limit=2", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /insights/tokens/sessions with invalid window should return bad request", func(t *testing.T) {
+		for _, query := range []string{
+			"",
+			"start_time=2023-06-25T15:04:05Z",
+			"start_time=2023-06-25T18:04:05Z&end_time=2023-06-25T15:04:05Z",
+			window + "&limit=0",
+		} {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, "/insights/tokens/sessions?"+query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockDataService.AssertNotCalled(t, "GetTokenUsagePerSession")
+		}
+	})
+
+	t.Run("GET /insights/tokens/sessions with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetTokenUsagePerSession", startTime, endTime, 0, 100).Return([]models.SessionTokenUsage{}, 0, errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/insights/tokens/sessions?"+window, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching token usage per session")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestGraphDeterminism(t *testing.T) {
+	startTime := time.Date(2023, 6, 25, 15, 4, 5, 0, time.UTC)
+	endTime := time.Date(2023, 6, 25, 18, 4, 5, 0, time.UTC)
+	window := "start_time=2023-06-25T15:04:05Z&end_time=2023-06-25T18:04:05Z"
+
+	t.Run("GET /insights/graph-determinism should return the determinism of the app", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		expected := models.GraphDeterminism{
+			AppName:           "ml-service",
+			SessionCount:      4,
+			UniquePathCount:   2,
+			DominantPath:      []string{"agent", "llm_call", "tool_call"},
+			DominantPathCount: 3,
+			Score:             0.75,
+		}
+		mockDataService.On("GetGraphDeterminism", "ml-service", startTime, endTime).Return(expected, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/insights/graph-determinism?app_name=ml-service&"+window, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response models.GraphDeterminism
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, response)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /insights/graph-determinism with invalid parameters should return bad request", func(t *testing.T) {
+		for _, query := range []string{
+			window,
+			"app_name=ml-service",
+			"app_name=ml-service&start_time=2023-06-25T18:04:05Z&end_time=2023-06-25T15:04:05Z",
+		} {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, "/insights/graph-determinism?"+query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockDataService.AssertNotCalled(t, "GetGraphDeterminism")
+		}
+	})
+
+	t.Run("GET /insights/graph-determinism with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetGraphDeterminism", "ml-service", startTime, endTime).Return(models.GraphDeterminism{}, errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/insights/graph-determinism?app_name=ml-service&"+window, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error computing graph determinism")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestMetricMatrix(t *testing.T) {
+	floatPtr := func(f float64) *float64 { return &f }
+
+	t.Run("GET /insights/metrics/matrix should return the averages per app and key", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		matrix := models.MetricMatrix{
+			Keys: []string{"accuracy", "latency_ms"},
+			Apps: []models.MetricMatrixRow{
+				{AppName: "api-gateway", Values: map[string]*float64{"accuracy": nil, "latency_ms": floatPtr(85)}},
+				{AppName: "ml-service", Values: map[string]*float64{"accuracy": floatPtr(0.9), "latency_ms": floatPtr(120.5)}},
+			},
+		}
+		mockDataService.On("GetMetricMatrix", []string{"accuracy", "latency_ms"}, "session").Return(matrix, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/insights/metrics/matrix?keys=accuracy,%20latency_ms,accuracy", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"keys": ["accuracy", "latency_ms"],
+			"apps": [
+				{"app_name": "api-gateway", "values": {"accuracy": null, "latency_ms": 85}},
+				{"app_name": "ml-service", "values": {"accuracy": 0.9, "latency_ms": 120.5}}
+			]
+		}`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /insights/metrics/matrix should read the requested scope", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricMatrix", []string{"accuracy"}, "span").Return(models.MetricMatrix{Keys: []string{"accuracy"}, Apps: []models.MetricMatrixRow{}}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/insights/metrics/matrix?keys=accuracy&scope=span", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /insights/metrics/matrix with invalid parameters should return bad request", func(t *testing.T) {
+		tooManyKeys := make([]string, common.METRIC_MATRIX_MAX_KEYS+1)
+		for i := range tooManyKeys {
+			tooManyKeys[i] = fmt.Sprintf("key_%d", i)
+		}
+		for _, query := range []string{"", "?keys=,%20,", "?keys=accuracy&scope=trace", "?keys=" + strings.Join(tooManyKeys, ",")} {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, "/insights/metrics/matrix"+query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockDataService.AssertNotCalled(t, "GetMetricMatrix")
+		}
+	})
+
+	t.Run("GET /insights/metrics/matrix with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricMatrix", []string{"accuracy"}, "session").Return(models.MetricMatrix{}, errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/insights/metrics/matrix?keys=accuracy", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching metric matrix")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestSpanDurations(t *testing.T) {
+	startTime := "2023-06-25T15:04:05Z"
+	endTime := "2023-06-25T18:04:05Z"
+	expectedStats := []models.SpanDurationStat{
+		{SpanName: "llm_call", Count: 12, AvgDurationMs: 850.5, MaxDurationMs: 2300, P95DurationMs: 2100},
+		{SpanName: "tool_call", Count: 30, AvgDurationMs: 42.25, MaxDurationMs: 120, P95DurationMs: 95},
+	}
+
+	t.Run("GET /insights/spans/durations should return stats per span name", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetSpanDurationStats", (*string)(nil), time.Date(2023, 6, 25, 15, 4, 5, 0, time.UTC), time.Date(2023, 6, 25, 18, 4, 5, 0, time.UTC)).Return(expectedStats, nil)
+
+		url := fmt.Sprintf("/insights/spans/durations?start_time=%s&end_time=%s", startTime, endTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response []models.SpanDurationStat
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedStats, response)
+		assert.Contains(t, w.Body.String(), `"avg_duration_ms":850.5`)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /insights/spans/durations should pass service name", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetSpanDurationStats", mock.MatchedBy(func(serviceName *string) bool {
+			return serviceName != nil && *serviceName == "ml-service"
+		}), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return(expectedStats[:1], nil)
+
+		url := fmt.Sprintf("/insights/spans/durations?start_time=%s&end_time=%s&service_name=ml-service", startTime, endTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /insights/spans/durations with invalid end_time should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		url := fmt.Sprintf("/insights/spans/durations?start_time=%s&end_time=invalid", startTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid end_time")
+	})
+
+	t.Run("GET /insights/spans/durations with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetSpanDurationStats", (*string)(nil), mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time")).Return([]models.SpanDurationStat{}, errors.New("database error"))
+
+		url := fmt.Sprintf("/insights/spans/durations?start_time=%s&end_time=%s", startTime, endTime)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching span duration stats")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestWriteMetricsSession(t *testing.T) {
+	t.Run("POST /metrics/session with valid payload should create metric", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		spanID := "span_abc123"
+		traceID := "trace_def456"
+		sessionID := "session_ghi789"
+		appName := "ml-service"
+		appID := "app-001"
+		metricsJSON := models.JSONRawMessage(`{"accuracy":"0.95","latency_ms":"120"}`)
+
+		metricRequest := models.MetricCreateRequest{
+			SpanId:    &spanID,
+			TraceId:   &traceID,
+			SessionId: &sessionID,
+			Metrics:   &metricsJSON,
+			AppName:   &appName,
+			AppId:     &appID,
+		}
+
+		// Create expected metric with generated ID and timestamp
+		expectedMetric := models.Metric{
+			ID:        stringPtr("generated-uuid"),
+			SpanId:    &spanID,
+			TraceId:   &traceID,
+			SessionId: &sessionID,
+			TimeStamp: timePtr(time.Now()),
+			Metrics:   &metricsJSON,
+			AppName:   &appName,
+			AppId:     &appID,
+			Scope:     stringPtr(common.METRIC_SCOPE_SESSION),
+		}
+
+		mockDataService.On("AddMetric", mock.MatchedBy(func(m models.Metric) bool {
+			return *m.SpanId == spanID && *m.TraceId == traceID && *m.SessionId == sessionID &&
+				*m.AppName == appName && *m.AppId == appID && *m.Scope == common.METRIC_SCOPE_SESSION
+		})).Return(expectedMetric, nil)
+
+		body, _ := json.Marshal(metricRequest)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSession(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response models.MetricResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMetric.ID, response.ID)
+		assert.Equal(t, expectedMetric.SpanId, response.SpanId)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("POST /metrics/session with invalid JSON should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		req := httptest.NewRequest(http.MethodPost, "/metrics/session", strings.NewReader("invalid json"))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSession(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Error decoding request body")
+	})
+
+	t.Run("POST /metrics/session with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		spanID := "span_abc123"
+		traceID := "trace_def456"
+		sessionID := "session_ghi789"
+		appName := "ml-service"
+		appID := "app-001"
+		metricsJSON := models.JSONRawMessage(`{"accuracy":"0.95"}`)
+
+		metricRequest := models.MetricCreateRequest{
+			SpanId:    &spanID,
+			TraceId:   &traceID,
+			SessionId: &sessionID,
+			Metrics:   &metricsJSON,
+			AppName:   &appName,
+			AppId:     &appID,
+		}
+
+		mockDataService.On("AddMetric", mock.AnythingOfType("models.Metric")).Return(models.Metric{}, errors.New("database error"))
+
+		body, _ := json.Marshal(metricRequest)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSession(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error writing metric")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/session should return method not allowed", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session", nil)
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSession(w, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
+
+func TestWriteMetricsKeyAllowlist(t *testing.T) {
+	newRequest := func(appName string, metrics string) *http.Request {
+		metricsJSON := models.JSONRawMessage(metrics)
+		body, _ := json.Marshal(models.MetricCreateRequest{
+			SpanId:    stringPtr("span_abc123"),
+			TraceId:   stringPtr("trace_def456"),
+			SessionId: stringPtr("session_ghi789"),
+			Metrics:   &metricsJSON,
+			AppName:   stringPtr(appName),
+			AppId:     stringPtr("app-001"),
+		})
+		return httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBuffer(body))
+	}
+	allowlist := map[string][]string{"ml-service": {"accuracy", "latency_ms"}}
+
+	t.Run("Allowed keys should be written", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.MetricKeyAllowlist = allowlist
+
+		mockDataService.On("AddMetric", mock.AnythingOfType("models.Metric")).Return(models.Metric{ID: stringPtr("generated-uuid")}, nil)
+
+		w := httptest.NewRecorder()
+		server.WriteMetricsSession(w, newRequest("ml-service", `{"accuracy":"0.95","latency_ms":"120"}`))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("Apps without an allowlist should be unrestricted", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.MetricKeyAllowlist = allowlist
+
+		mockDataService.On("AddMetric", mock.AnythingOfType("models.Metric")).Return(models.Metric{ID: stringPtr("generated-uuid")}, nil)
+
+		w := httptest.NewRecorder()
+		server.WriteMetricsSession(w, newRequest("api-gateway", `{"anything":"1"}`))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("Partially allowed keys should be rejected by default", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.MetricKeyAllowlist = allowlist
+
+		w := httptest.NewRecorder()
+		server.WriteMetricsSession(w, newRequest("ml-service", `{"accuracy":"0.95","debug":"x","tokens":"3"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Metric keys not allowed for app ml-service: debug, tokens")
+		mockDataService.AssertNotCalled(t, "AddMetric", mock.Anything)
+	})
+
+	t.Run("Partially allowed keys should be dropped in drop mode", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.MetricKeyAllowlist = allowlist
+		server.MetricKeyAllowlistMode = common.METRIC_KEY_ALLOWLIST_MODE_DROP
+
+		mockDataService.On("AddMetric", mock.MatchedBy(func(m models.Metric) bool {
+			return string(*m.Metrics) == `{"accuracy":"0.95"}`
+		})).Return(models.Metric{ID: stringPtr("generated-uuid")}, nil)
+
+		w := httptest.NewRecorder()
+		server.WriteMetricsSession(w, newRequest("ml-service", `{"accuracy":"0.95","debug":"x"}`))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("Fully disallowed keys should be rejected in drop mode", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.MetricKeyAllowlist = allowlist
+		server.MetricKeyAllowlistMode = common.METRIC_KEY_ALLOWLIST_MODE_DROP
+
+		w := httptest.NewRecorder()
+		server.WriteMetricsSession(w, newRequest("ml-service", `{"debug":"x"}`))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Metric keys not allowed for app ml-service: debug")
+		mockDataService.AssertNotCalled(t, "AddMetric", mock.Anything)
+	})
+}
+
+func TestWriteMetricsStrictJSON(t *testing.T) {
+	// sesion_id is a typo of session_id
+	body := `{"span_id":"span_abc123","trace_id":"trace_def456","sesion_id":"session_ghi789","metrics":{"accuracy":"0.95"},"app_name":"ml-service","app_id":"app-001"}`
+
+	t.Run("Unknown fields should be ignored by default", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		// The body decodes but session_id is missing, so the metric cannot be written
+		mockDataService.On("AddMetric", mock.MatchedBy(func(metric models.Metric) bool {
+			return metric.SessionId == nil
+		})).Return(models.Metric{}, errors.New("cannot create Metric: required fields are empty"))
+
+		w := httptest.NewRecorder()
+		server.WriteMetricsSession(w, httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBufferString(body)))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "required fields are empty")
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("Unknown fields should be rejected in strict mode", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.StrictJSON = true
+
+		w := httptest.NewRecorder()
+		server.WriteMetricsSession(w, httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBufferString(body)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `unknown field \"sesion_id\"`)
+		mockDataService.AssertNotCalled(t, "AddMetric", mock.Anything)
+	})
+
+	t.Run("Known fields should be accepted in strict mode", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.StrictJSON = true
+
+		mockDataService.On("AddMetric", mock.AnythingOfType("models.Metric")).Return(models.Metric{ID: stringPtr("generated-uuid")}, nil)
+
+		validBody := strings.Replace(body, "sesion_id", "session_id", 1)
+		w := httptest.NewRecorder()
+		server.WriteMetricsSession(w, httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBufferString(validBody)))
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("Unknown fields should be rejected on session checks in strict mode", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.StrictJSON = true
+
+		w := httptest.NewRecorder()
+		server.SessionsExist(w, httptest.NewRequest(http.MethodPost, "/traces/sessions/exists", bytes.NewBufferString(`{"session_id":["session_abc123"]}`)))
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `unknown field \"session_id\"`)
+	})
+}
+
+func TestWriteMetricsUpsert(t *testing.T) {
+	spanID := "span_abc123"
+	traceID := "trace_def456"
+	sessionID := "session_ghi789"
+	appName := "ml-service"
+	appID := "app-001"
+	metricsJSON := models.JSONRawMessage(`{"accuracy":"0.97"}`)
+
+	metricRequest := models.MetricCreateRequest{
+		SpanId:    &spanID,
+		TraceId:   &traceID,
+		SessionId: &sessionID,
+		Metrics:   &metricsJSON,
+		AppName:   &appName,
+		AppId:     &appID,
+	}
+
+	expectedMetric := models.Metric{
+		ID:        stringPtr("generated-uuid"),
+		SpanId:    &spanID,
+		TraceId:   &traceID,
+		SessionId: &sessionID,
+		TimeStamp: timePtr(time.Now()),
+		Metrics:   &metricsJSON,
+		AppName:   &appName,
+		AppId:     &appID,
+		Scope:     stringPtr(common.METRIC_SCOPE_SESSION),
+	}
+
+	t.Run("POST /metrics/session?upsert=true should upsert metric", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		mockDataService.On("UpsertMetric", mock.MatchedBy(func(m models.Metric) bool {
+			return *m.SpanId == spanID && *m.SessionId == sessionID && *m.Scope == common.METRIC_SCOPE_SESSION
+		})).Return(expectedMetric, nil)
+
+		body, _ := json.Marshal(metricRequest)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/session?upsert=true", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSession(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response models.MetricResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMetric.ID, response.ID)
+
+		mockDataService.AssertExpectations(t)
+		mockDataService.AssertNotCalled(t, "AddMetric", mock.Anything)
+	})
+
+	t.Run("POST /metrics/span without upsert should append metric", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		mockDataService.On("AddMetric", mock.AnythingOfType("models.Metric")).Return(expectedMetric, nil)
+
+		body, _ := json.Marshal(metricRequest)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/span?upsert=false", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSpan(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		mockDataService.AssertExpectations(t)
+		mockDataService.AssertNotCalled(t, "UpsertMetric", mock.Anything)
+	})
+
+	t.Run("POST /metrics/session?upsert=true with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		mockDataService.On("UpsertMetric", mock.AnythingOfType("models.Metric")).Return(models.Metric{}, errors.New("database error"))
+
+		body, _ := json.Marshal(metricRequest)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/session?upsert=true", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSession(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error writing metric")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("POST /metrics/session with upsert spelled as a boolean should upsert metric", func(t *testing.T) {
+		for _, value := range []string{"1", "True", "TRUE"} {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+
+			mockDataService.On("UpsertMetric", mock.AnythingOfType("models.Metric")).Return(expectedMetric, nil)
+
+			body, _ := json.Marshal(metricRequest)
+			req := httptest.NewRequest(http.MethodPost, "/metrics/session?upsert="+value, bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			server.WriteMetricsSession(w, req)
+
+			assert.Equal(t, http.StatusCreated, w.Code, value)
+			mockDataService.AssertExpectations(t)
+		}
+	})
+
+	t.Run("POST /metrics/session with an invalid upsert value should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		body, _ := json.Marshal(metricRequest)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/session?upsert=yes", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSession(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid upsert")
+		mockDataService.AssertNotCalled(t, "UpsertMetric", mock.Anything)
+		mockDataService.AssertNotCalled(t, "AddMetric", mock.Anything)
+	})
+
+	t.Run("POST /metrics/session?upsert=true without span_id should upsert on the empty span", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		mockDataService.On("UpsertMetric", mock.MatchedBy(func(m models.Metric) bool {
+			return m.SpanId == nil && *m.Scope == common.METRIC_SCOPE_SESSION
+		})).Return(models.Metric{ID: stringPtr("generated-uuid")}, nil)
+
+		incomplete := metricRequest
+		incomplete.SpanId = nil
+		body, _ := json.Marshal(incomplete)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/session?upsert=true", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSession(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("POST /metrics/span?upsert=true without span_id should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+
+		mockDataService.On("UpsertMetric", mock.MatchedBy(func(m models.Metric) bool {
+			return m.SpanId == nil && *m.Scope == common.METRIC_SCOPE_SPAN
+		})).Return(models.Metric{}, models.ErrIncompleteUpsert)
+
+		incomplete := metricRequest
+		incomplete.SpanId = nil
+		body, _ := json.Marshal(incomplete)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/span?upsert=true", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSpan(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "span_id for span metrics")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestWriteMetricsSpan(t *testing.T) {
+	mockDataService := new(MockDataService)
+	server := createTestServer(mockDataService)
+
+	t.Run("POST /metrics/span with valid payload should create metric", func(t *testing.T) {
+		spanID := "span_xyz789"
+		traceID := "trace_uvw123"
+		sessionID := "session_rst456"
+		appName := "api-gateway"
+		appID := "app-002"
+		metricsJSON := models.JSONRawMessage(`{"response_time":"200","cache_hit":"true"}`)
+
+		metricRequest := models.MetricCreateRequest{
+			SpanId:    &spanID,
+			TraceId:   &traceID,
+			SessionId: &sessionID,
+			Metrics:   &metricsJSON,
+			AppName:   &appName,
+			AppId:     &appID,
+		}
+
+		expectedMetric := models.Metric{
+			ID:        stringPtr("generated-uuid"),
+			SpanId:    &spanID,
+			TraceId:   &traceID,
+			SessionId: &sessionID,
+			TimeStamp: timePtr(time.Now()),
+			Metrics:   &metricsJSON,
+			AppName:   &appName,
+			AppId:     &appID,
+			Scope:     stringPtr(common.METRIC_SCOPE_SPAN),
+		}
+
+		mockDataService.On("AddMetric", mock.MatchedBy(func(m models.Metric) bool {
+			return *m.SpanId == spanID && *m.TraceId == traceID && *m.SessionId == sessionID &&
+				*m.AppName == appName && *m.AppId == appID && *m.Scope == common.METRIC_SCOPE_SPAN
+		})).Return(expectedMetric, nil)
+
+		body, _ := json.Marshal(metricRequest)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/span", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		server.WriteMetricsSpan(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response models.MetricResponse
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMetric.ID, response.ID)
+		assert.Equal(t, expectedMetric.SpanId, response.SpanId)
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestGetMetricsSession(t *testing.T) {
+	t.Run("GET /metrics/session/{session_id} with valid session_id should return metrics", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		sessionID := "session_abc123"
+		expectedMetrics := []models.Metric{
+			{
+				ID:        stringPtr("metric_001"),
+				SpanId:    stringPtr("span_abc123"),
+				TraceId:   stringPtr("trace_def456"),
+				SessionId: &sessionID,
+				TimeStamp: timePtr(time.Date(2023, 6, 25, 15, 30, 0, 0, time.UTC)),
+				Metrics:   jsonRawMessagePtr(`{"accuracy":"0.95","latency_ms":"120"}`),
+				AppName:   stringPtr("ml-service"),
+				AppId:     stringPtr("app-001"),
+				Scope:     nil, // Scope is not included in JSON response due to json:"-" tag
+			},
+		}
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", sessionID, common.METRIC_SCOPE_SESSION, 0, 0, common.SORT_TIMESTAMP_DESC).Return(expectedMetrics, nil)
+
+		url := fmt.Sprintf("/metrics/session/%s", sessionID)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response []models.Metric
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMetrics, response)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/session/{session_id} with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		sessionID := "session_abc123"
+		mockDataService.On("GetMetricsBySessionIdAndScope", sessionID, common.METRIC_SCOPE_SESSION, 0, 0, common.SORT_TIMESTAMP_DESC).Return([]models.Metric{}, errors.New("database error"))
+
+		url := fmt.Sprintf("/metrics/session/%s", sessionID)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching metrics")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("POST /metrics/session/{session_id} should return method not allowed", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		url := "/metrics/session/session_abc123"
+		req := httptest.NewRequest(http.MethodPost, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	})
+}
+
+func TestGetMetricsSpan(t *testing.T) {
+	t.Run("GET /metrics/span/{span_id} with valid span_id should return metrics", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		spanID := "span_abc123"
+		expectedMetrics := []models.Metric{
+			{
+				ID:        stringPtr("metric_001"),
+				SpanId:    &spanID,
+				TraceId:   stringPtr("trace_def456"),
+				SessionId: stringPtr("session_abc123"),
+				TimeStamp: timePtr(time.Date(2023, 6, 25, 15, 30, 0, 0, time.UTC)),
+				Metrics:   jsonRawMessagePtr(`{"accuracy":"0.95","latency_ms":"120"}`),
+				AppName:   stringPtr("ml-service"),
+				AppId:     stringPtr("app-001"),
+				Scope:     nil, // Scope is not included in JSON response due to json:"-" tag
+			},
+		}
+
+		mockDataService.On("GetMetricsBySpanIdAndScope", spanID, common.METRIC_SCOPE_SPAN, 0, 0, common.SORT_TIMESTAMP_DESC).Return(expectedMetrics, nil)
+
+		url := fmt.Sprintf("/metrics/span/%s", spanID)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response []models.Metric
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMetrics, response)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/span/{span_id} with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		spanID := "span_abc123"
+		mockDataService.On("GetMetricsBySpanIdAndScope", spanID, common.METRIC_SCOPE_SPAN, 0, 0, common.SORT_TIMESTAMP_DESC).Return([]models.Metric{}, errors.New("database error"))
+
+		url := fmt.Sprintf("/metrics/span/%s", spanID)
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching metrics")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/span/ with empty span_id should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		url := "/metrics/span/"
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestGetMetricsPagination(t *testing.T) {
+	t.Run("GET /metrics/session/{session_id} should pass page, limit and sort to the service", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", common.METRIC_SCOPE_SESSION, 2, 25, common.SORT_TIMESTAMP_ASC).Return([]models.Metric{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123?page=2&limit=25&sort=timestamp_asc", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/span/{span_id} should pass page, limit and sort to the service", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySpanIdAndScope", "span_abc123", common.METRIC_SCOPE_SPAN, 0, 10, common.SORT_TIMESTAMP_DESC).Return([]models.Metric{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/span/span_abc123?limit=10&sort=timestamp_desc", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		mockDataService.AssertExpectations(t)
+	})
+
+	invalid := []struct {
+		name  string
+		query string
+		error string
+	}{
+		{name: "non-numeric page", query: "page=abc", error: "Invalid page"},
+		{name: "negative page", query: "page=-1", error: "Invalid page"},
+		{name: "zero limit", query: "limit=0", error: "Invalid limit"},
+		{name: "non-numeric limit", query: "limit=ten", error: "Invalid limit"},
+		{name: "unknown sort", query: "sort=name_asc", error: "Invalid sort"},
+	}
+
+	for _, tt := range invalid {
+		t.Run("GET /metrics/session/{session_id} with "+tt.name+" should return bad request", func(t *testing.T) {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.error)
+			mockDataService.AssertNotCalled(t, "GetMetricsBySessionIdAndScope")
+		})
+	}
+}
+
+func TestGetMetricsScope(t *testing.T) {
+	t.Run("GET /metrics/session/{session_id} with a valid scope should read that scope", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		expectedMetrics := []models.Metric{{ID: stringPtr("metric_001"), SessionId: stringPtr("session_abc123")}}
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", common.METRIC_SCOPE_SPAN, 0, 0, common.SORT_TIMESTAMP_DESC).Return(expectedMetrics, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123?scope=span", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response []models.Metric
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedMetrics, response)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/span/{span_id} with a valid scope should read that scope", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySpanIdAndScope", "span_abc123", common.METRIC_SCOPE_SESSION, 0, 0, common.SORT_TIMESTAMP_DESC).Return([]models.Metric{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/span/span_abc123?scope=session", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	for _, path := range []string{"/metrics/session/session_abc123", "/metrics/span/span_abc123"} {
+		t.Run("GET "+path+" with an invalid scope should return bad request", func(t *testing.T) {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, path+"?scope=sesion", nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `Invalid scope \"sesion\"`)
+			mockDataService.AssertNotCalled(t, "GetMetricsBySessionIdAndScope")
+			mockDataService.AssertNotCalled(t, "GetMetricsBySpanIdAndScope")
+		})
+	}
+}
+
+func TestGetMetricsTyped(t *testing.T) {
+	storedMetrics := func() []models.Metric {
+		return []models.Metric{{
+			ID:      stringPtr("metric_001"),
+			Metrics: jsonRawMessagePtr(`{"accuracy":"0.95","latency_ms":"120","error_type":"timeout"}`),
+		}}
+	}
+
+	t.Run("GET /metrics/session/{session_id} should return raw values by default", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", common.METRIC_SCOPE_SESSION, 0, 0, common.SORT_TIMESTAMP_DESC).Return(storedMetrics(), nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"metrics":{"accuracy":"0.95","latency_ms":"120","error_type":"timeout"}`)
+	})
+
+	t.Run("GET /metrics/session/{session_id}?typed=true should return numbers", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", common.METRIC_SCOPE_SESSION, 0, 0, common.SORT_TIMESTAMP_DESC).Return(storedMetrics(), nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123?typed=true", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"metrics":{"accuracy":0.95,"error_type":"timeout","latency_ms":120}`)
+	})
+
+	t.Run("GET /metrics/span/{span_id}?typed=true should return numbers", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySpanIdAndScope", "span_abc123", common.METRIC_SCOPE_SPAN, 0, 0, common.SORT_TIMESTAMP_DESC).Return(storedMetrics(), nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/span/span_abc123?typed=true", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"metrics":{"accuracy":0.95,"error_type":"timeout","latency_ms":120}`)
+	})
+}
+
+func TestMetricsSessionRollup(t *testing.T) {
+	for agg, value := range map[string]float64{"sum": 360, "avg": 120, "min": 80, "max": 200} {
+		t.Run("GET /metrics/session/{session_id}/rollup with agg="+agg+" should return the aggregated value", func(t *testing.T) {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			mockDataService.On("RollupSpanMetricsToSession", "session_abc123", "latency_ms", agg).Return(value, nil)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123/rollup?key=latency_ms&agg="+agg, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var response models.MetricRollup
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, models.MetricRollup{SessionID: "session_abc123", Key: "latency_ms", Agg: agg, Value: value}, response)
+
+			mockDataService.AssertExpectations(t)
+		})
+	}
+
+	t.Run("GET /metrics/session/{session_id}/rollup without key should return bad request", func(t *testing.T) {
+		server := createTestServer(new(MockDataService))
+		router := createTestRouter(server)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123/rollup?agg=sum", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "key parameter is required")
+	})
+
+	t.Run("GET /metrics/session/{session_id}/rollup with invalid agg should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123/rollup?key=latency_ms&agg=median", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `Invalid agg \"median\"`)
+		mockDataService.AssertNotCalled(t, "RollupSpanMetricsToSession", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("GET /metrics/session/{session_id}/rollup without values should return not found", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("RollupSpanMetricsToSession", "session_abc123", "latency_ms", "avg").Return(float64(0), models.ErrNoMetricValues)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123/rollup?key=latency_ms&agg=avg", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "No numeric values for metric latency_ms")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/session/{session_id}/rollup with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("RollupSpanMetricsToSession", "session_abc123", "latency_ms", "sum").Return(float64(0), errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123/rollup?key=latency_ms&agg=sum", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error rolling up metrics")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestMetricsCompare(t *testing.T) {
+	metricsOf := func(sessionID, metrics string) []models.Metric {
+		raw := models.JSONRawMessage(metrics)
+		return []models.Metric{{SessionId: stringPtr(sessionID), Metrics: &raw}}
+	}
+
+	t.Run("GET /metrics/compare should merge shared and divergent keys", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", "session", 0, 0, "timestamp_desc").
+			Return(metricsOf("session_abc123", `{"latency_ms":"120","accuracy":"0.95"}`), nil)
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_def456", "session", 0, 0, "timestamp_desc").
+			Return(metricsOf("session_def456", `{"latency_ms":"100","tokens":"42"}`), nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/compare?session_a=session_abc123&session_b=session_def456", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{
+			"session_a": "session_abc123",
+			"session_b": "session_def456",
+			"scope": "session",
+			"metrics": {
+				"latency_ms": {"session_a": "120", "session_b": "100", "delta": -20},
+				"accuracy": {"session_a": "0.95", "session_b": null},
+				"tokens": {"session_a": null, "session_b": "42"}
+			}
+		}`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/compare should read the requested scope", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", "span", 0, 0, "timestamp_desc").Return([]models.Metric{}, nil)
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_def456", "span", 0, 0, "timestamp_desc").Return([]models.Metric{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/compare?session_a=session_abc123&session_b=session_def456&scope=span", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"session_a":"session_abc123","session_b":"session_def456","scope":"span","metrics":{}}`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/compare with missing or invalid parameters should return bad request", func(t *testing.T) {
+		for _, query := range []string{"", "?session_a=session_abc123", "?session_b=session_def456", "?session_a=session_abc123&session_b=session_def456&scope=trace"} {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics/compare"+query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockDataService.AssertNotCalled(t, "GetMetricsBySessionIdAndScope")
+		}
+	})
+
+	t.Run("GET /metrics/compare with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", "session", 0, 0, "timestamp_desc").Return([]models.Metric{}, errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/compare?session_a=session_abc123&session_b=session_def456", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching metrics for session ID session_abc123")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestMetricSpans(t *testing.T) {
+	t.Run("GET /metrics/spans should return only the spans with metrics", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		// span_2 of the session has no metric
+		expectedSpans := []models.SpanMetricCount{
+			{SpanId: "span_1", MetricCount: 3},
+			{SpanId: "span_3", MetricCount: 1},
+		}
+		mockDataService.On("GetSpansWithMetrics", "session_abc123", "span").Return(expectedSpans, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/spans?session_id=session_abc123", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `[{"span_id":"span_1","metric_count":3},{"span_id":"span_3","metric_count":1}]`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/spans should read the requested scope", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetSpansWithMetrics", "session_abc123", "session").Return([]models.SpanMetricCount{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/spans?session_id=session_abc123&scope=session", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]\n", w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/spans with missing session_id or invalid scope should return bad request", func(t *testing.T) {
+		for _, query := range []string{"", "?scope=span", "?session_id=session_abc123&scope=trace"} {
+			mockDataService := new(MockDataService)
+			server := createTestServer(mockDataService)
+			router := createTestRouter(server)
+
+			req := httptest.NewRequest(http.MethodGet, "/metrics/spans"+query, nil)
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			mockDataService.AssertNotCalled(t, "GetSpansWithMetrics")
+		}
+	})
+
+	t.Run("GET /metrics/spans with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetSpansWithMetrics", "session_abc123", "span").Return([]models.SpanMetricCount{}, errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/spans?session_id=session_abc123", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching spans with metrics")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestMetricApps(t *testing.T) {
+	expectedApps := []models.AppInfo{
+		{AppName: "api-gateway", AppId: "app-002"},
+		{AppName: "ml-service", AppId: "app-001"},
+		{AppName: "ml-service", AppId: "app-003"},
+	}
+
+	t.Run("GET /metrics/apps should return every app", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetDistinctMetricApps", (*time.Time)(nil), (*time.Time)(nil)).Return(expectedApps, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/apps", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response []models.AppInfo
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedApps, response)
+		assert.Contains(t, w.Body.String(), `{"app_name":"api-gateway","app_id":"app-002"}`)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/apps should pass the time range", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		startTime := time.Date(2023, 6, 25, 15, 4, 5, 0, time.UTC)
+		endTime := time.Date(2023, 6, 25, 18, 4, 5, 0, time.UTC)
+		mockDataService.On("GetDistinctMetricApps", &startTime, &endTime).Return(expectedApps[:1], nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/apps?start_time=2023-06-25T15:04:05Z&end_time=2023-06-25T18:04:05Z", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /metrics/apps with invalid start_time should return bad request", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/apps?start_time=invalid", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Invalid start_time")
+	})
+
+	t.Run("GET /metrics/apps with service error should return internal server error", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetDistinctMetricApps", (*time.Time)(nil), (*time.Time)(nil)).Return([]models.AppInfo{}, errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/apps", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching metric apps")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestSessionEvents(t *testing.T) {
+	t.Run("GET /traces/session/{session_id}/events should return the events", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		expectedEvents := []models.SpanEvent{
+			{TraceId: "trace_def456", SpanId: "span_abc123", SpanName: "agent", Timestamp: time.Date(2023, 6, 25, 15, 30, 0, 0, time.UTC), Name: "tool_call", Attributes: map[string]string{"tool.name": "search"}},
+			{TraceId: "trace_def456", SpanId: "span_abc124", SpanName: "llm", Timestamp: time.Date(2023, 6, 25, 15, 31, 0, 0, time.UTC), Name: "retry", Attributes: map[string]string{}},
+		}
+		mockDataService.On("GetSessionEvents", "session_abc123", (*string)(nil)).Return(expectedEvents, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123/events", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response []models.SpanEvent
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedEvents, response)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET /traces/session/{session_id}/events should pass the event name filter", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetSessionEvents", "session_abc123", stringPtr("tool_call")).Return([]models.SpanEvent{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123/events?event_name=tool_call", nil)
 		w := httptest.NewRecorder()
 
-		server.WriteMetricsSession(w, req)
+		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
-		assert.Contains(t, w.Body.String(), "Error writing metric")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "[]\n", w.Body.String())
 
 		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("GET /metrics/session should return method not allowed", func(t *testing.T) {
+	t.Run("GET /traces/session/{session_id}/events with service error should return internal server error", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
 
-		req := httptest.NewRequest(http.MethodGet, "/metrics/session", nil)
+		mockDataService.On("GetSessionEvents", "session_abc123", (*string)(nil)).Return([]models.SpanEvent{}, errors.New("database error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123/events", nil)
 		w := httptest.NewRecorder()
 
-		server.WriteMetricsSession(w, req)
+		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching events")
+
+		mockDataService.AssertExpectations(t)
 	})
 }
 
-func TestWriteMetricsSpan(t *testing.T) {
-	mockDataService := new(MockDataService)
-	server := createTestServer(mockDataService)
-
-	t.Run("POST /metrics/span with valid payload should create metric", func(t *testing.T) {
-		spanID := "span_xyz789"
-		traceID := "trace_uvw123"
-		sessionID := "session_rst456"
-		appName := "api-gateway"
-		appID := "app-002"
-		metricsJSON := models.JSONRawMessage(`{"response_time":"200","cache_hit":"true"}`)
-
-		metricRequest := models.MetricCreateRequest{
-			SpanId:    &spanID,
-			TraceId:   &traceID,
-			SessionId: &sessionID,
-			Metrics:   &metricsJSON,
-			AppName:   &appName,
-			AppId:     &appID,
-		}
+func TestCallGraphEdges(t *testing.T) {
+	t.Run("GET /traces/session/{session_id}/callgraph/edges should return edges with counts", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
 
-		expectedMetric := models.Metric{
-			ID:        stringPtr("generated-uuid"),
-			SpanId:    &spanID,
-			TraceId:   &traceID,
-			SessionId: &sessionID,
-			TimeStamp: timePtr(time.Now()),
-			Metrics:   &metricsJSON,
-			AppName:   &appName,
-			AppId:     &appID,
-			Scope:     stringPtr(common.METRIC_SCOPE_SPAN),
+		expectedEdges := []models.CallGraphEdge{
+			{FromSpan: "agent", ToSpan: "llm_call", Count: 3},
+			{FromSpan: "llm_call", ToSpan: "agent", Count: 2},
+			{FromSpan: "START", ToSpan: "agent", Count: 1},
+			{FromSpan: "llm_call", ToSpan: "END", Count: 1},
 		}
+		mockDataService.On("GetCallGraphEdges", "session_abc123").Return(expectedEdges, nil)
 
-		mockDataService.On("AddMetric", mock.MatchedBy(func(m models.Metric) bool {
-			return *m.SpanId == spanID && *m.TraceId == traceID && *m.SessionId == sessionID &&
-				*m.AppName == appName && *m.AppId == appID && *m.Scope == common.METRIC_SCOPE_SPAN
-		})).Return(expectedMetric, nil)
-
-		body, _ := json.Marshal(metricRequest)
-		req := httptest.NewRequest(http.MethodPost, "/metrics/span", bytes.NewBuffer(body))
-		req.Header.Set("Content-Type", "application/json")
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123/callgraph/edges", nil)
 		w := httptest.NewRecorder()
 
-		server.WriteMetricsSpan(w, req)
+		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), `{"from_span":"agent","to_span":"llm_call","count":3}`)
 
-		var response models.MetricResponse
+		var response []models.CallGraphEdge
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, expectedMetric.ID, response.ID)
-		assert.Equal(t, expectedMetric.SpanId, response.SpanId)
+		assert.Equal(t, expectedEdges, response)
 
 		mockDataService.AssertExpectations(t)
 	})
-}
 
-func TestGetMetricsSession(t *testing.T) {
-	t.Run("GET /metrics/session/{session_id} with valid session_id should return metrics", func(t *testing.T) {
+	t.Run("GET /traces/session/{session_id}/callgraph/edges with service error should return internal server error", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 		router := createTestRouter(server)
 
-		sessionID := "session_abc123"
-		expectedMetrics := []models.Metric{
-			{
-				ID:        stringPtr("metric_001"),
-				SpanId:    stringPtr("span_abc123"),
-				TraceId:   stringPtr("trace_def456"),
-				SessionId: &sessionID,
-				TimeStamp: timePtr(time.Date(2023, 6, 25, 15, 30, 0, 0, time.UTC)),
-				Metrics:   jsonRawMessagePtr(`{"accuracy":"0.95","latency_ms":"120"}`),
-				AppName:   stringPtr("ml-service"),
-				AppId:     stringPtr("app-001"),
-				Scope:     nil, // Scope is not included in JSON response due to json:"-" tag
-			},
-		}
+		mockDataService.On("GetCallGraphEdges", "session_abc123").Return([]models.CallGraphEdge{}, errors.New("database error"))
 
-		mockDataService.On("GetMetricsBySessionIdAndScope", sessionID, common.METRIC_SCOPE_SESSION).Return(expectedMetrics, nil)
+		req := httptest.NewRequest(http.MethodGet, "/traces/session/session_abc123/callgraph/edges", nil)
+		w := httptest.NewRecorder()
 
-		url := fmt.Sprintf("/metrics/session/%s", sessionID)
-		req := httptest.NewRequest(http.MethodGet, url, nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching call graph edges")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestSessionConversation(t *testing.T) {
+	t.Run("GET /sessions/{session_id}/conversation should return the messages", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		timestamp := time.Date(2023, 6, 25, 15, 30, 0, 0, time.UTC)
+		mockDataService.On("GetSessionConversation", "session_abc123").Return([]models.ConversationMessage{
+			{Role: "user", Content: "Book a flight to Paris.", SpanId: "span_1", Timestamp: timestamp},
+			{Role: "assistant", Content: "Which date?", SpanId: "span_1", Timestamp: timestamp},
+		}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/sessions/session_abc123/conversation", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
-
-		var response []models.Metric
-		err := json.Unmarshal(w.Body.Bytes(), &response)
-		assert.NoError(t, err)
-		assert.Equal(t, expectedMetrics, response)
+		assert.JSONEq(t, `{"session_id": "session_abc123", "messages": [
+			{"role": "user", "content": "Book a flight to Paris.", "span_id": "span_1", "timestamp": "2023-06-25T15:30:00Z"},
+			{"role": "assistant", "content": "Which date?", "span_id": "span_1", "timestamp": "2023-06-25T15:30:00Z"}
+		]}`, w.Body.String())
 
 		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("GET /metrics/session/{session_id} with service error should return internal server error", func(t *testing.T) {
+	t.Run("GET for a session without gen_ai attributes should return an empty conversation", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 		router := createTestRouter(server)
 
-		sessionID := "session_abc123"
-		mockDataService.On("GetMetricsBySessionIdAndScope", sessionID, common.METRIC_SCOPE_SESSION).Return([]models.Metric{}, errors.New("database error"))
+		mockDataService.On("GetSessionConversation", "session_abc123").Return([]models.ConversationMessage(nil), nil)
 
-		url := fmt.Sprintf("/metrics/session/%s", sessionID)
-		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req := httptest.NewRequest(http.MethodGet, "/sessions/session_abc123/conversation", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusInternalServerError, w.Code)
-		assert.Contains(t, w.Body.String(), "Error fetching metrics")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"session_id": "session_abc123", "messages": []}`, w.Body.String())
 
 		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("POST /metrics/session/{session_id} should return method not allowed", func(t *testing.T) {
+	t.Run("GET with database error should return 500", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 		router := createTestRouter(server)
 
-		url := "/metrics/session/session_abc123"
-		req := httptest.NewRequest(http.MethodPost, url, nil)
+		mockDataService.On("GetSessionConversation", "session_abc123").Return([]models.ConversationMessage(nil), errors.New("database connection error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/sessions/session_abc123/conversation", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching conversation for session session_abc123")
+
+		mockDataService.AssertExpectations(t)
 	})
 }
 
-func TestGetMetricsSpan(t *testing.T) {
-	t.Run("GET /metrics/span/{span_id} with valid span_id should return metrics", func(t *testing.T) {
+func TestPurgeSession(t *testing.T) {
+	t.Run("DELETE /sessions/{session_id} should return the rows deleted per table", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 		router := createTestRouter(server)
 
-		spanID := "span_abc123"
-		expectedMetrics := []models.Metric{
-			{
-				ID:        stringPtr("metric_001"),
-				SpanId:    &spanID,
-				TraceId:   stringPtr("trace_def456"),
-				SessionId: stringPtr("session_abc123"),
-				TimeStamp: timePtr(time.Date(2023, 6, 25, 15, 30, 0, 0, time.UTC)),
-				Metrics:   jsonRawMessagePtr(`{"accuracy":"0.95","latency_ms":"120"}`),
-				AppName:   stringPtr("ml-service"),
-				AppId:     stringPtr("app-001"),
-				Scope:     nil, // Scope is not included in JSON response due to json:"-" tag
-			},
+		expected := models.PurgeResult{
+			SessionID: "session_abc123",
+			Deleted:   map[string]int64{"otel_traces": 42, "derived_metrics": 3},
 		}
+		mockDataService.On("PurgeSession", "session_abc123").Return(expected, nil)
 
-		mockDataService.On("GetMetricsBySpanIdAndScope", spanID, common.METRIC_SCOPE_SPAN).Return(expectedMetrics, nil)
-
-		url := fmt.Sprintf("/metrics/span/%s", spanID)
-		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req := httptest.NewRequest(http.MethodDelete, "/sessions/session_abc123", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
@@ -814,46 +3180,58 @@ func TestGetMetricsSpan(t *testing.T) {
 		assert.Equal(t, http.StatusOK, w.Code)
 		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
 
-		var response []models.Metric
+		var response models.PurgeResult
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		assert.NoError(t, err)
-		assert.Equal(t, expectedMetrics, response)
+		assert.Equal(t, expected, response)
 
 		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("GET /metrics/span/{span_id} with service error should return internal server error", func(t *testing.T) {
+	t.Run("DELETE /sessions/{session_id} with service error should return the counts and failed tables", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
 		router := createTestRouter(server)
 
-		spanID := "span_abc123"
-		mockDataService.On("GetMetricsBySpanIdAndScope", spanID, common.METRIC_SCOPE_SPAN).Return([]models.Metric{}, errors.New("database error"))
+		partial := models.PurgeResult{
+			SessionID: "session_abc123",
+			Deleted:   map[string]int64{"otel_traces": 42, "derived_metrics": 3},
+			Failed:    []string{"derived_metrics"},
+		}
+		mockDataService.On("PurgeSession", "session_abc123").Return(partial, errors.New("derived_metrics: database error"))
 
-		url := fmt.Sprintf("/metrics/span/%s", spanID)
-		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req := httptest.NewRequest(http.MethodDelete, "/sessions/session_abc123", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
 		assert.Equal(t, http.StatusInternalServerError, w.Code)
-		assert.Contains(t, w.Body.String(), "Error fetching metrics")
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+		var response models.PurgeResult
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+		assert.Equal(t, partial.Deleted, response.Deleted)
+		assert.Equal(t, []string{"derived_metrics"}, response.Failed)
+		assert.Equal(t, "Error purging session ID session_abc123: derived_metrics: database error", response.Error)
 
 		mockDataService.AssertExpectations(t)
 	})
 
-	t.Run("GET /metrics/span/ with empty span_id should return bad request", func(t *testing.T) {
+	t.Run("DELETE /sessions/{session_id} should be rejected in read-only mode", func(t *testing.T) {
 		mockDataService := new(MockDataService)
 		server := createTestServer(mockDataService)
+		server.ReadOnly = true
 		router := createTestRouter(server)
+		router.Use(server.readOnlyMiddleware)
 
-		url := "/metrics/span/"
-		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req := httptest.NewRequest(http.MethodDelete, "/sessions/session_abc123", nil)
 		w := httptest.NewRecorder()
 
 		router.ServeHTTP(w, req)
 
-		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		mockDataService.AssertNotCalled(t, "PurgeSession", mock.Anything)
 	})
 }
 
@@ -937,6 +3315,165 @@ func TestSpanBySessionAndSpanID(t *testing.T) {
 	})
 }
 
+func TestTraceStats(t *testing.T) {
+	t.Run("GET /traces/trace/{trace_id}/stats should return the trace statistics", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		stats := models.TraceStats{
+			TraceId:         "trace_ghi789",
+			SpanCount:       3,
+			ErrorSpanCount:  1,
+			Services:        []string{"api-gateway", "ml-service"},
+			TotalDurationMs: 1500,
+		}
+		mockDataService.On("GetTraceStats", "trace_ghi789").Return(stats, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/trace/trace_ghi789/stats", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+		assert.JSONEq(t, `{"trace_id": "trace_ghi789", "span_count": 3, "error_span_count": 1, "services": ["api-gateway", "ml-service"], "total_duration_ms": 1500}`, w.Body.String())
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET with a trace without spans should return 404", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetTraceStats", "trace_nonexistent").Return(models.TraceStats{}, gorm.ErrRecordNotFound)
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/trace/trace_nonexistent/stats", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "Trace not found")
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("GET with database error should return 500", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+
+		mockDataService.On("GetTraceStats", "trace_ghi789").Return(models.TraceStats{}, errors.New("database connection error"))
+
+		req := httptest.NewRequest(http.MethodGet, "/traces/trace/trace_ghi789/stats", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "Error fetching stats for trace")
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
+func TestReadOnlyMiddleware(t *testing.T) {
+	metricsJSON := models.JSONRawMessage(`{"accuracy":"0.95"}`)
+	metricRequest := models.MetricCreateRequest{
+		SpanId:    stringPtr("span_abc123"),
+		TraceId:   stringPtr("trace_def456"),
+		SessionId: stringPtr("session_ghi789"),
+		Metrics:   &metricsJSON,
+		AppName:   stringPtr("ml-service"),
+		AppId:     stringPtr("app-001"),
+	}
+
+	t.Run("Writes should be rejected in read-only mode", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.ReadOnly = true
+		router := createTestRouter(server)
+		router.Use(server.readOnlyMiddleware)
+
+		for _, path := range []string{"/metrics/session", "/metrics/span"} {
+			body, _ := json.Marshal(metricRequest)
+			req := httptest.NewRequest(http.MethodPost, path, bytes.NewBuffer(body))
+			w := httptest.NewRecorder()
+
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code, path)
+			assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+			var response SimpleMessage
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Contains(t, response.Message, "read-only")
+		}
+
+		mockDataService.AssertNotCalled(t, "AddMetric", mock.Anything)
+	})
+
+	t.Run("Reads should keep working in read-only mode", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.ReadOnly = true
+		router := createTestRouter(server)
+		router.Use(server.readOnlyMiddleware)
+
+		mockDataService.On("GetMetricsBySessionIdAndScope", "session_abc123", common.METRIC_SCOPE_SESSION, 0, 0, common.SORT_TIMESTAMP_DESC).Return([]models.Metric{}, nil)
+
+		req := httptest.NewRequest(http.MethodGet, "/metrics/session/session_abc123", nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("Read-only POST endpoints should keep working in read-only mode", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		server.ReadOnly = true
+		router := createTestRouter(server)
+		router.Use(server.readOnlyMiddleware)
+
+		mockDataService.On("CheckSessionsExist", []string{"session_abc123"}).Return([]string{"session_abc123"}, []string{}, nil)
+
+		req := httptest.NewRequest(http.MethodPost, "/traces/sessions/exists", bytes.NewBufferString(`{"session_ids":["session_abc123"]}`))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+
+	t.Run("Writes should be allowed when read-only mode is off", func(t *testing.T) {
+		mockDataService := new(MockDataService)
+		server := createTestServer(mockDataService)
+		router := createTestRouter(server)
+		router.Use(server.readOnlyMiddleware)
+
+		mockDataService.On("AddMetric", mock.AnythingOfType("models.Metric")).Return(models.Metric{ID: stringPtr("generated-uuid")}, nil)
+
+		body, _ := json.Marshal(metricRequest)
+		req := httptest.NewRequest(http.MethodPost, "/metrics/session", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		mockDataService.AssertExpectations(t)
+	})
+}
+
 // Helper functions for creating pointers
 func stringPtr(s string) *string {
 	return &s
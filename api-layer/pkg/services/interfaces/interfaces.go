@@ -11,12 +11,31 @@ import (
 
 // DataService defines the interface for data operations
 type DataService interface {
+	Ping() error
 	GetSessionIDSUnique(startTime, endTime time.Time) ([]models.SessionUniqueID, error)
+	GetSessionIDSUniqueWithPagination(startTime, endTime time.Time, page, limit int, nameFilter *string, cursor string) ([]models.SessionUniqueID, int, string, error)
 	GetSessionIDSWithPrompts(startTime, endTime time.Time) ([]models.SessionUniqueID, error)
+	GetSessionSpanCounts(sessionIDs []string) (map[string]models.SessionSpanCount, error)
+	GetSessionConversation(sessionID string) ([]models.ConversationMessage, error)
+	PurgeSession(sessionID string) (models.PurgeResult, error)
+	CheckSessionsExist(sessionIDs []string) (present []string, missing []string, err error)
 	AddMetric(metric models.Metric) (models.Metric, error)
-	GetMetricsBySessionIdAndScope(sessionID string, scope string) ([]models.Metric, error)
-	GetMetricsBySpanIdAndScope(spanID string, scope string) ([]models.Metric, error)
+	UpsertMetric(metric models.Metric) (models.Metric, error)
+	GetMetricsBySessionIdAndScope(sessionID string, scope string, page, limit int, sort string) ([]models.Metric, error)
+	GetMetricsBySpanIdAndScope(spanID string, scope string, page, limit int, sort string) ([]models.Metric, error)
+	RollupSpanMetricsToSession(sessionID, metricKey, agg string) (float64, error)
+	GetDistinctMetricApps(startTime, endTime *time.Time) ([]models.AppInfo, error)
+	GetSpansWithMetrics(sessionID string, scope string) ([]models.SpanMetricCount, error)
+	GetMetricMatrix(keys []string, scope string) (models.MetricMatrix, error)
 	GetTracesBySessionID(sessionID string) ([]models.OtelTraces, error)
+	StreamTracesBySessionID(sessionID string, fn func(models.OtelTraces) error) error
 	GetTracesBySessionIDs(sessionIDs []string) (map[string][]models.OtelTraces, []string, error)
 	GetSpanBySessionIDAndSpanID(sessionID string, spanID string) (models.OtelTraces, error)
+	GetTraceStats(traceID string) (models.TraceStats, error)
+	GetCallGraphEdges(sessionID string) ([]models.CallGraphEdge, error)
+	GetSessionEvents(sessionID string, eventName *string) ([]models.SpanEvent, error)
+	GetTokenUsagePerSession(startTime, endTime time.Time, page, limit int) ([]models.SessionTokenUsage, int, error)
+	GetGraphDeterminism(appName string, startTime, endTime time.Time) (models.GraphDeterminism, error)
+	GetSpanDurationStats(serviceName *string, startTime, endTime time.Time) ([]models.SpanDurationStat, error)
+	GetTraceAttributeKeys(serviceName *string, startTime, endTime time.Time, limit int) ([]string, error)
 }